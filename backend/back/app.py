@@ -3,7 +3,7 @@ import os
 from quart import Quart, render_template, request, redirect, url_for, session
 from quart_auth import QuartAuth, login_required, AuthUser, login_user, logout_user, current_user, Unauthorized
 from dotenv import load_dotenv
-from db import fetch_vulnerabilities, fetch_vulnerability_details, fetch_cve_nvd_details, fetch_opencve_details, fetch_statistics, fetch_ubi, fetch_ubi_details
+from db import fetch_vulnerabilities, fetch_vulnerability_details, fetch_cve_nvd_details, fetch_opencve_details, fetch_vulnerability_ubi, fetch_vulnerability_kev, fetch_vulnerability_epss, fetch_vulnerability_vendors, fetch_statistics, fetch_ubi, fetch_ubi_details
 
 # Загрузка переменных окружения из файла .env
 load_dotenv()
@@ -29,9 +29,10 @@ async def index(page=1):
     Главная страница с перечнем уязвимостей. Поддерживает поиск и пагинацию.
     """
     search = request.args.get('search')
-    vulnerabilities = await fetch_vulnerabilities(page=page, search=search)
-    logging.info(f"Пользователь {current_user.auth_id} запросил главную страницу, страница: {page}, поиск: {search}")
-    return await render_template('index.html', vulnerabilities=vulnerabilities, page=page, search=search)
+    sort = request.args.get('sort')
+    vulnerabilities = await fetch_vulnerabilities(page=page, search=search, sort=sort)
+    logging.info(f"Пользователь {current_user.auth_id} запросил главную страницу, страница: {page}, поиск: {search}, сортировка: {sort}")
+    return await render_template('index.html', vulnerabilities=vulnerabilities, page=page, search=search, sort=sort)
 
 
 @app.route('/details/<int:vul_id>')
@@ -43,8 +44,12 @@ async def details(vul_id):
     vulnerability = await fetch_vulnerability_details(vul_id)
     cve_nvd = await fetch_cve_nvd_details(vul_id)
     opencve = await fetch_opencve_details(vul_id)
+    ubi_links = await fetch_vulnerability_ubi(vul_id)
+    kev = await fetch_vulnerability_kev(vul_id)
+    epss = await fetch_vulnerability_epss(vul_id)
+    vendors = await fetch_vulnerability_vendors(vul_id)
     logging.info(f"Пользователь {current_user.auth_id} запросил детали уязвимости с ID: {vul_id}")
-    return await render_template('details.html', vulnerability=vulnerability, cve_nvd=cve_nvd, opencve=opencve)
+    return await render_template('details.html', vulnerability=vulnerability, cve_nvd=cve_nvd, opencve=opencve, ubi_links=ubi_links, kev=kev, epss=epss, vendors=vendors)
 
 
 @app.route('/ubi')
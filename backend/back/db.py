@@ -19,22 +19,25 @@ async def get_db_connection():
     )
     return conn
 
-async def fetch_vulnerabilities(page=1, per_page=15, search=None):
+async def fetch_vulnerabilities(page=1, per_page=15, search=None, sort=None):
     """
-    Извлекает список уязвимостей из базы данных с поддержкой пагинации и поиска.
+    Извлекает список уязвимостей из базы данных с поддержкой пагинации, поиска и сортировки.
 
     :param page: номер страницы
     :param per_page: количество записей на страницу
     :param search: строка поиска
+    :param sort: epss - сортировка по вероятности эксплуатации, иначе по идентификатору BDU
     :return: список уязвимостей
     """
-    logging.info(f"Запрос уязвимостей, страница: {page}, поиск: {search}")
+    logging.info(f"Запрос уязвимостей, страница: {page}, поиск: {search}, сортировка: {sort}")
     conn = await get_db_connection()
     
     query = """
-    SELECT vulnerability.id, vulnerability.identifier, vulnerability.name, vulnerability.severity, cve_identifier.link AS cve
+    SELECT vulnerability.id, vulnerability.identifier, vulnerability.name, vulnerability.severity, cve_identifier.link AS cve,
+           vulnerability_epss.epss
     FROM vulnerability
     LEFT JOIN cve_identifier ON vulnerability.id = cve_identifier.vulnerability_id
+    LEFT JOIN vulnerability_epss ON vulnerability.id = vulnerability_epss.vulnerability_id
     WHERE TRUE
     """
     params = []
@@ -44,7 +47,10 @@ async def fetch_vulnerabilities(page=1, per_page=15, search=None):
         search_param = f"%{search}%"
         params.extend([search_param, search_param])
     
-    query += " ORDER BY vulnerability.identifier DESC"
+    if sort == "epss":
+        query += " ORDER BY vulnerability_epss.epss DESC NULLS LAST, vulnerability.identifier DESC"
+    else:
+        query += " ORDER BY vulnerability.identifier DESC"
     offset = (page - 1) * per_page
     query += f" OFFSET {offset} LIMIT {per_page}"
     
@@ -66,7 +72,9 @@ async def fetch_vulnerability_details(vul_id):
     SELECT vulnerability.*, 
            array_agg(DISTINCT cve_identifier.link) AS cve_links,
            array_agg(DISTINCT software.name || ' ' || software.version || ' ' || software.platform) AS software_details,
-           array_agg(DISTINCT os.name || ' ' || os.version || ' ' || os.platform) AS os_details
+           array_agg(DISTINCT os.name || ' ' || os.version || ' ' || os.platform) AS os_details,
+           EXISTS (SELECT 1 FROM vulnerability_cve_rejected
+                   WHERE vulnerability_cve_rejected.vulnerability_id = vulnerability.id) AS cve_rejected
     FROM vulnerability
     LEFT JOIN cve_identifier ON vulnerability.id = cve_identifier.vulnerability_id
     LEFT JOIN software ON vulnerability.id = software.vulnerability_id
@@ -81,26 +89,36 @@ async def fetch_vulnerability_details(vul_id):
 
 async def fetch_cve_nvd_details(vul_id):
     """
-    Извлекает детали CVE из NVD по идентификатору уязвимости.
+    Извлекает детали всех CVE из NVD, связанных с уязвимостью.
 
     :param vul_id: идентификатор уязвимости
-    :return: детали CVE из NVD
+    :return: список деталей CVE из NVD
     """
     logging.info(f"Запрос деталей CVE NVD для уязвимости с ID: {vul_id}")
     conn = await get_db_connection()
     query = """
-    SELECT cve_link, description, hyperlinks
-    FROM cve_nvd
-    WHERE vulnerability_id = $1
+    SELECT cve_nvd.cve_id, cve_nvd.description, cve_nvd.vuln_status,
+           cve_nvd.published, cve_nvd.last_modified, cve_nvd.rejection_reason,
+           COALESCE(array_agg(cve_nvd_reference.url ORDER BY cve_nvd_reference.url)
+                    FILTER (WHERE cve_nvd_reference.url IS NOT NULL), '{}') AS hyperlinks,
+           COALESCE(bool_or('Patch' = ANY(cve_nvd_reference.tags)), FALSE) AS has_patch,
+           COALESCE(bool_or('Exploit' = ANY(cve_nvd_reference.tags)), FALSE) AS has_exploit
+    FROM cve_nvd_vulnerability
+    JOIN cve_nvd ON cve_nvd.id = cve_nvd_vulnerability.cve_nvd_id
+    LEFT JOIN cve_nvd_reference ON cve_nvd_reference.cve_nvd_id = cve_nvd.id
+    WHERE cve_nvd_vulnerability.vulnerability_id = $1
+    GROUP BY cve_nvd.id
+    ORDER BY cve_nvd.cve_id
     """
-    result = await conn.fetchrow(query, vul_id)
+    result = await conn.fetch(query, vul_id)
     await conn.close()
     
     return result
 
 async def fetch_opencve_details(vul_id):
     """
-    Извлекает детали из OpenCVE по идентификатору уязвимости.
+    Извлекает детали из OpenCVE по идентификатору уязвимости: метрики CVE
+    с наибольшей базовой оценкой среди CVE уязвимости.
 
     :param vul_id: идентификатор уязвимости
     :return: детали из OpenCVE
@@ -108,9 +126,10 @@ async def fetch_opencve_details(vul_id):
     logging.info(f"Запрос деталей OpenCVE для уязвимости с ID: {vul_id}")
     conn = await get_db_connection()
     query = """
-    SELECT attack_vector, attack_complexity, privileges_required, user_interaction, 
-    confidentiality_impact, integrity_impact, availability_impact, scope
-    FROM cve_opencve
+    SELECT cve_id, attack_vector, attack_complexity, privileges_required, user_interaction, 
+    confidentiality_impact, integrity_impact, availability_impact, scope,
+    cvss_version, vector_string, base_score, base_severity
+    FROM vulnerability_opencve
     WHERE vulnerability_id = $1
     """
     result = await conn.fetchrow(query, vul_id)
@@ -118,6 +137,92 @@ async def fetch_opencve_details(vul_id):
     
     return result
 
+async def fetch_vulnerability_ubi(vul_id):
+    """
+    Извлекает угрозы УБИ, которые реализует уязвимость.
+
+    :param vul_id: идентификатор уязвимости
+    :return: список связанных УБИ
+    """
+    logging.info(f"Запрос связанных УБИ для уязвимости с ID: {vul_id}")
+    conn = await get_db_connection()
+    query = """
+    SELECT ubi.id, ubi.threat_id, ubi.name, vulnerability_ubi.reason
+    FROM vulnerability_ubi
+    JOIN ubi ON ubi.id = vulnerability_ubi.ubi_id
+    WHERE vulnerability_ubi.vulnerability_id = $1
+    ORDER BY ubi.threat_id
+    """
+    results = await conn.fetch(query, vul_id)
+    await conn.close()
+    
+    return results
+
+async def fetch_vulnerability_kev(vul_id):
+    """
+    Извлекает записи каталога CISA KEV для CVE уязвимости.
+
+    :param vul_id: идентификатор уязвимости
+    :return: список эксплуатируемых CVE уязвимости
+    """
+    logging.info(f"Запрос данных CISA KEV для уязвимости с ID: {vul_id}")
+    conn = await get_db_connection()
+    query = """
+    SELECT cve_id, date_added, required_action, due_date, known_ransomware_campaign_use
+    FROM vulnerability_kev
+    WHERE vulnerability_id = $1
+    ORDER BY cve_id
+    """
+    results = await conn.fetch(query, vul_id)
+    await conn.close()
+    
+    return results
+
+async def fetch_vulnerability_vendors(vul_id):
+    """
+    Извлекает производителей и продукты CVE уязвимости по данным NVD и OpenCVE.
+
+    :param vul_id: идентификатор уязвимости
+    :return: список производителей с их продуктами
+    """
+    logging.info(f"Запрос производителей для уязвимости с ID: {vul_id}")
+    conn = await get_db_connection()
+    query = """
+    SELECT vv.vendor, array_remove(array_agg(DISTINCT vp.product ORDER BY vp.product), NULL) AS products
+    FROM vulnerability_vendor vv
+    LEFT JOIN vulnerability_product vp ON vp.vulnerability_id = vv.vulnerability_id AND vp.vendor = vv.vendor
+    WHERE vv.vulnerability_id = $1
+    GROUP BY vv.vendor
+    ORDER BY vv.vendor
+    """
+    results = await conn.fetch(query, vul_id)
+    await conn.close()
+    
+    return results
+
+async def fetch_vulnerability_epss(vul_id, days=30):
+    """
+    Извлекает историю оценок EPSS для CVE уязвимости.
+
+    :param vul_id: идентификатор уязвимости
+    :param days: количество последних дат оценки
+    :return: оценки EPSS, начиная с последней даты
+    """
+    logging.info(f"Запрос оценок EPSS для уязвимости с ID: {vul_id}")
+    conn = await get_db_connection()
+    query = """
+    SELECT cve_epss.cve_id, cve_epss.score_date, cve_epss.epss, cve_epss.percentile
+    FROM cve_identifier
+    JOIN cve_epss ON cve_epss.cve_id = cve_identifier.link
+    WHERE cve_identifier.vulnerability_id = $1
+      AND cve_epss.score_date > (SELECT max(score_date) FROM cve_epss) - $2::int
+    ORDER BY cve_epss.cve_id, cve_epss.score_date DESC
+    """
+    results = await conn.fetch(query, vul_id, days)
+    await conn.close()
+    
+    return results
+
 async def fetch_ubi(page=1, per_page=15):
     """
     Извлекает список УБИ (угроз безопасности информации) с поддержкой пагинации.
@@ -130,9 +235,10 @@ async def fetch_ubi(page=1, per_page=15):
     conn = await get_db_connection()
     
     query = """
-    SELECT id, name, source
+    SELECT id, threat_id, name, source
     FROM ubi
-    ORDER BY id ASC
+    WHERE NOT removed
+    ORDER BY threat_id ASC
     OFFSET $1 LIMIT $2
     """
     offset = (page - 1) * per_page
@@ -180,7 +286,7 @@ async def fetch_statistics():
         (SELECT COUNT(*) FROM cve_identifier) AS total_cve,
         (SELECT COUNT(*) FROM cve_nvd) AS total_cve_nvd,
         (SELECT COUNT(*) FROM cve_opencve) AS total_cve_opencve,
-        (SELECT COUNT(*) FROM ubi) AS total_ubi
+        (SELECT COUNT(*) FROM ubi WHERE NOT removed) AS total_ubi
     """
     
     result = await conn.fetchrow(query)
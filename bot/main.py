@@ -13,8 +13,11 @@ from aiogram.fsm.context import FSMContext
 from aiogram.fsm.state import StatesGroup, State
 from aiogram.types import InlineKeyboardButton, InlineKeyboardMarkup, Message, CallbackQuery
 from aiogram import F
+from aiogram.exceptions import TelegramForbiddenError
 
-from sql import get_stats, search_by_bdu, search_by_cve, get_last_cve, get_ubi
+from sql import get_stats, search_by_bdu, search_by_cve, get_last_cve, get_ubi, search_by_vendor
+from sql import create_subscription_tables, subscribe_vendor, unsubscribe_vendor, unsubscribe_chat, get_subscriptions
+from sql import get_new_vendor_links, mark_vendor_links_notified
 
 # Загрузка переменных окружения из файла .env
 load_dotenv()
@@ -27,6 +30,13 @@ if not TOKEN:
     logging.error("Токен бота не задан в файле .env")
     sys.exit(1)
 
+# Период проверки новых уязвимостей по подпискам на производителей в секундах
+try:
+    VENDOR_NOTIFY_INTERVAL = int(os.getenv('VENDOR_NOTIFY_INTERVAL') or 3600)
+except ValueError:
+    logging.error("Некорректное значение VENDOR_NOTIFY_INTERVAL, используется 3600")
+    VENDOR_NOTIFY_INTERVAL = 3600
+
 # Инициализация диспетчера
 dp = Dispatcher()
 
@@ -42,6 +52,16 @@ class UbiState(StatesGroup):
     waiting_for_ubi_id = State()
 
 
+class VendorState(StatesGroup):
+    """Состояния для FSM поиска по производителю"""
+    waiting_for_vendor = State()
+
+
+class SubscriptionState(StatesGroup):
+    """Состояния для FSM подписки на производителя"""
+    waiting_for_vendor = State()
+
+
 @dp.message(F.text, Command("start"))
 async def start_command(message: Message):
     """
@@ -52,7 +72,11 @@ async def start_command(message: Message):
                          "\- /search \- выполняет поиск по идентификатору CVE или BDU\n"
                          "\- /glossary \- справочная информация\n"
                          "\- /statistic \- статистика базы данных\n"
-                         "\- /search\_ubi \- выполняет поиск по идентификатору УБИ", parse_mode=ParseMode.MARKDOWN_V2)
+                         "\- /search\_ubi \- выполняет поиск по идентификатору УБИ\n"
+                         "\- /search\_vendor \- выполняет поиск уязвимостей по производителю\n"
+                         "\- /subscribe\_vendor \- подписка на новые уязвимости производителя\n"
+                         "\- /unsubscribe\_vendor \- отмена подписки на производителя\n"
+                         "\- /subscriptions \- список подписок", parse_mode=ParseMode.MARKDOWN_V2)
 
 
 @dp.message(F.text, Command("statistic"))
@@ -184,10 +208,11 @@ async def handle_identifier(message: Message, state: FSMContext):
     if search_type == "BDU":
         result = await search_by_bdu(identifier)
     else:
-        result = await search_by_cve(identifier)
+        # Идентификаторы CVE хранятся в каноническом виде CVE-YYYY-NNNN
+        result = await search_by_cve(identifier.upper())
 
     if result:
-        vulnerability_data, software_data, os_data, cve_nvd_data, cve_opencve_data, only_cve = result
+        vulnerability_data, software_data, os_data, cve_nvd_data, cve_opencve_data, only_cve, ubi_data = result
 
         message_text = f"<b>Найдено по {search_type}:</b>\n\n"
         message_text += f"<b>Уязвимость по ФСТЭК:</b>\n"
@@ -226,15 +251,27 @@ async def handle_identifier(message: Message, state: FSMContext):
                 message_text += f"<b>Версия:</b> {row['version']}\n"
                 message_text += f"<b>Платформа:</b> {row['platform']}\n"
 
+        if ubi_data:
+            message_text += f"\n<b>Реализуемые угрозы (УБИ):</b>\n"
+            for row in ubi_data:
+                message_text += f"<b>УБИ.{row['threat_id']:03d}:</b> {row['name']}\n"
+
         if cve_nvd_data:
             message_text += f"\n<b>Информация из NVD:</b>\n"
+            if all(row['vuln_status'] == 'Rejected' for row in cve_nvd_data) and len(cve_nvd_data) == len(only_cve):
+                message_text += f"<b>Внимание:</b> все CVE уязвимости отклонены в NVD\n"
             for row in cve_nvd_data:
-                message_text += f"<b>Ссылка на NVD:</b> {row['cve_link']}\n"
+                message_text += f"<b>Ссылка на NVD:</b> https://nvd.nist.gov/vuln/detail/{row['cve_id']}\n"
+                message_text += f"<b>Статус NVD:</b> {row['vuln_status']}\n"
+                if row['rejection_reason']:
+                    message_text += f"<b>Причина отклонения:</b> {row['rejection_reason']}\n"
                 message_text += f"<b>Описание:</b> {row['description']}\n"
                 message_text += f"<b>Ссылки из NVD:</b> {row['hyperlinks']}\n"
 
         if cve_opencve_data:
             message_text += f"\n<b>Информация из OpenCVE:</b>\n"
+            message_text += f"<b>CVE:</b> {cve_opencve_data[0]['cve_id']}\n"
+            message_text += f"<b>Оценка CVSS:</b> {cve_opencve_data[0]['base_score']} ({cve_opencve_data[0]['base_severity']})\n"
             message_text += f"<b>Вектор атаки:</b> {cve_opencve_data[0]['attack_vector']}\n"
             message_text += f"<b>Сложность атаки:</b> {cve_opencve_data[0]['attack_complexity']}\n"
             message_text += f"<b>Необходимые права:</b> {cve_opencve_data[0]['privileges_required']}\n"
@@ -278,7 +315,7 @@ async def handle_ubi_id(message: Message, state: FSMContext):
         ubi_data = result[0]
         message_text = (
             f"<b>Информация по УБИ:</b>\n\n"
-            f"<b>Идентификатор:</b> {html.escape(str(ubi_data['id']))}\n"
+            f"<b>Идентификатор:</b> {html.escape(str(ubi_data['threat_id']))}\n"
             f"<b>Название:</b> {html.escape(ubi_data['name'])}\n"
             f"<b>Описание:</b> {html.escape(ubi_data['description'])}\n"
             f"<b>Источник:</b> {html.escape(ubi_data['source'])}\n"
@@ -291,12 +328,179 @@ async def handle_ubi_id(message: Message, state: FSMContext):
     else:
         await message.answer(f"Информация по УБИ с идентификатором {html.escape(ubi_id_str)} не найдена.", parse_mode=ParseMode.HTML)
 
+
+@dp.message(Command("search_vendor"))
+async def start_search_vendor(message: Message, state: FSMContext):
+    """
+    Обработка команды /search_vendor для начала поиска по производителю.
+    """
+    await message.answer("Введите производителя в терминах CPE \(например, microsoft\):")
+    await state.set_state(VendorState.waiting_for_vendor)
+
+
+@dp.message(VendorState.waiting_for_vendor)
+async def handle_vendor(message: Message, state: FSMContext):
+    """
+    Обработка введенного производителя.
+    """
+    vendor = message.text.strip().lower()
+    await state.clear()
+
+    result = await search_by_vendor(vendor)
+
+    if result:
+        message_text = f"<b>Уязвимости БДУ производителя {html.escape(vendor)}:</b> {len(result)}\n\n"
+        for row in result:
+            message_text += f"<b>{html.escape(row['identifier'])}</b> {html.escape(row['name'] or '')}\n"
+        await send_large_message(message, message_text, parse_mode=ParseMode.HTML)
+    else:
+        await message.answer(f"Уязвимости производителя {html.escape(vendor)} не найдены.", parse_mode=ParseMode.HTML)
+
+@dp.message(Command("subscribe_vendor"))
+async def start_subscribe_vendor(message: Message, state: FSMContext):
+    """
+    Обработка команды /subscribe_vendor для подписки на новые уязвимости производителя.
+    """
+    await message.answer("Введите производителя в терминах CPE \(например, microsoft\), "
+                         "о новых уязвимостях которого нужно присылать уведомления:")
+    await state.set_state(SubscriptionState.waiting_for_vendor)
+
+
+@dp.message(SubscriptionState.waiting_for_vendor)
+async def handle_subscribe_vendor(message: Message, state: FSMContext):
+    """
+    Обработка введенного производителя для подписки.
+    """
+    vendor = message.text.strip().lower()
+    await state.clear()
+
+    # Производитель передается в callback_data кнопки отмены подписки, которая ограничена 64 байтами
+    if not vendor or len(f"unsubscribe:{vendor}".encode()) > 64:
+        await message.answer("Некорректное имя производителя.", parse_mode=ParseMode.HTML)
+        return
+
+    result = await subscribe_vendor(message.chat.id, vendor)
+    if result is None:
+        await message.answer("Произошла ошибка при оформлении подписки.", parse_mode=ParseMode.HTML)
+    elif result:
+        await message.answer(f"Подписка на производителя {html.escape(vendor)} оформлена. "
+                             f"Уведомления будут приходить об уязвимостях, связанных с ним после подписки.", parse_mode=ParseMode.HTML)
+    else:
+        await message.answer(f"Подписка на производителя {html.escape(vendor)} уже оформлена.", parse_mode=ParseMode.HTML)
+
+
+@dp.message(Command("unsubscribe_vendor"))
+async def start_unsubscribe_vendor(message: Message):
+    """
+    Обработка команды /unsubscribe_vendor: выбор подписки для отмены.
+    """
+    subscriptions = await get_subscriptions(message.chat.id)
+    if subscriptions is None:
+        await message.answer("Произошла ошибка при получении подписок.", parse_mode=ParseMode.HTML)
+        return
+    if not subscriptions:
+        await message.answer("Подписок нет.", parse_mode=ParseMode.HTML)
+        return
+
+    keyboard = InlineKeyboardMarkup(
+        inline_keyboard=[
+            [InlineKeyboardButton(text=row['vendor'], callback_data=f"unsubscribe:{row['vendor']}")]
+            for row in subscriptions
+        ]
+    )
+    await message.answer("Выберите производителя для отмены подписки:", reply_markup=keyboard)
+
+
+@dp.callback_query(F.data.startswith("unsubscribe:"))
+async def handle_unsubscribe_vendor(call: CallbackQuery):
+    """
+    Обработка выбора производителя для отмены подписки.
+    """
+    await call.answer()
+    vendor = call.data.split(":", 1)[1]
+    if await unsubscribe_vendor(call.message.chat.id, vendor):
+        await call.message.answer(f"Подписка на производителя {html.escape(vendor)} отменена.", parse_mode=ParseMode.HTML)
+    else:
+        await call.message.answer("Произошла ошибка при отмене подписки.", parse_mode=ParseMode.HTML)
+
+
+@dp.message(Command("subscriptions"))
+async def show_subscriptions(message: Message):
+    """
+    Обработка команды /subscriptions для отображения подписок на производителей.
+    """
+    subscriptions = await get_subscriptions(message.chat.id)
+    if subscriptions is None:
+        await message.answer("Произошла ошибка при получении подписок.", parse_mode=ParseMode.HTML)
+    elif subscriptions:
+        message_text = "<b>Подписки на производителей:</b>\n"
+        for row in subscriptions:
+            message_text += f"{html.escape(row['vendor'])}\n"
+        await message.answer(message_text, parse_mode=ParseMode.HTML)
+    else:
+        await message.answer("Подписок нет.", parse_mode=ParseMode.HTML)
+
+
+async def notify_subscribers(bot: Bot):
+    """
+    Отправка уведомлений о новых связях уязвимостей БДУ с производителями, на которых подписаны чаты.
+    Уязвимости отмечаются отправленными только после успешной отправки сообщения.
+    """
+    links = await get_new_vendor_links()
+    if not links:
+        return
+
+    grouped = {}
+    for row in links:
+        grouped.setdefault((row['chat_id'], row['vendor']), []).append(row)
+
+    blocked = set()
+    for (chat_id, vendor), rows in grouped.items():
+        if chat_id in blocked:
+            continue
+        message_text = f"<b>Новые уязвимости производителя {html.escape(vendor)}:</b> {len(rows)}\n\n"
+        for row in rows:
+            message_text += f"<b>{html.escape(row['identifier'])}</b> {html.escape(row['name'] or '')}\n"
+        try:
+            for part in split_message(message_text, 4096):
+                await bot.send_message(chat_id, part, parse_mode=ParseMode.HTML)
+        except TelegramForbiddenError:
+            # Бот заблокирован или удален из чата: подписки чата больше не нужны
+            logging.info(f"Чат {chat_id} недоступен, подписки удалены")
+            blocked.add(chat_id)
+            await unsubscribe_chat(chat_id)
+            continue
+        except Exception as e:
+            logging.error(f"Ошибка при отправке уведомления в чат {chat_id}: {e}")
+            continue
+        await mark_vendor_links_notified(chat_id, vendor, [row['vulnerability_id'] for row in rows])
+        logging.info(f"Отправлено уведомление в чат {chat_id} о {len(rows)} уязвимостях производителя {vendor}")
+
+
+async def run_notifier(bot: Bot):
+    """
+    Периодическая проверка новых уязвимостей по подпискам на производителей.
+    """
+    while True:
+        try:
+            await notify_subscribers(bot)
+        except Exception as e:
+            logging.error(f"Ошибка при проверке подписок: {e}")
+        await asyncio.sleep(VENDOR_NOTIFY_INTERVAL)
+
+
 async def main() -> None:
     """
     Основная функция для запуска бота.
     """
     bot = Bot(token=TOKEN, default=DefaultBotProperties(parse_mode=ParseMode.MARKDOWN_V2))
-    await dp.start_polling(bot)
+    if not await create_subscription_tables():
+        logging.error("Не удалось создать таблицы подписок, уведомления не отправляются")
+    notifier = asyncio.create_task(run_notifier(bot))
+    try:
+        await dp.start_polling(bot)
+    finally:
+        notifier.cancel()
 
 if __name__ == "__main__":
     logging.debug("Бот запущен")
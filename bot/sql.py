@@ -54,7 +54,7 @@ async def get_stats():
         total_cve = await fetch_from_db("SELECT COUNT(*) FROM cve_identifier")
         total_cve_nvd = await fetch_from_db("SELECT COUNT(*) FROM cve_nvd")
         total_cve_opencve = await fetch_from_db("SELECT COUNT(*) FROM cve_opencve")
-        total_ubi = await fetch_from_db("SELECT COUNT(*) FROM ubi")
+        total_ubi = await fetch_from_db("SELECT COUNT(*) FROM ubi WHERE NOT removed")
         return {
             "total_vulnerabilities": total_vulnerabilities[0][0],
             "total_software": total_software[0][0],
@@ -85,12 +85,14 @@ async def search_by_bdu(identifier):
         os_data = await fetch_from_db(
             "SELECT * FROM os WHERE vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1)", identifier)
         cve_nvd_data = await fetch_from_db(
-            "SELECT * FROM cve_nvd WHERE vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1)", identifier)
+            "SELECT cve_id, description, vuln_status, rejection_reason, (SELECT string_agg(url, ' ' ORDER BY url) FROM cve_nvd_reference WHERE cve_nvd_reference.cve_nvd_id = cve_nvd.id) AS hyperlinks FROM cve_nvd JOIN cve_nvd_vulnerability ON cve_nvd_vulnerability.cve_nvd_id = cve_nvd.id WHERE cve_nvd_vulnerability.vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1) ORDER BY cve_id", identifier)
         cve_opencve_data = await fetch_from_db(
-            "SELECT * FROM cve_opencve WHERE vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1)", identifier)
+            "SELECT * FROM vulnerability_opencve WHERE vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1)", identifier)
         only_cve = await fetch_from_db(
             "SELECT link FROM cve_identifier WHERE vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1)", identifier)
-        return vulnerability_data, software_data, os_data, cve_nvd_data, cve_opencve_data, only_cve
+        ubi_data = await fetch_from_db(
+            "SELECT ubi.threat_id, ubi.name FROM vulnerability_ubi JOIN ubi ON ubi.id = vulnerability_ubi.ubi_id WHERE vulnerability_ubi.vulnerability_id = (SELECT id FROM vulnerability WHERE identifier = $1) ORDER BY ubi.threat_id", identifier)
+        return vulnerability_data, software_data, os_data, cve_nvd_data, cve_opencve_data, only_cve, ubi_data
     except Exception as e:
         logging.error(f"Произошла ошибка при поиске по BDU: {e}")
         return None
@@ -108,16 +110,18 @@ async def search_by_cve(identifier):
         vulnerability_data = await fetch_from_db(
             "SELECT * FROM vulnerability WHERE id IN (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
         software_data = await fetch_from_db(
-            "SELECT * FROM software WHERE vulnerability_id = (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
+            "SELECT * FROM software WHERE vulnerability_id IN (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
         os_data = await fetch_from_db(
-            "SELECT * FROM os WHERE vulnerability_id = (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
+            "SELECT * FROM os WHERE vulnerability_id IN (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
         cve_nvd_data = await fetch_from_db(
-            "SELECT * FROM cve_nvd WHERE vulnerability_id = (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
+            "SELECT cve_id, description, vuln_status, rejection_reason, (SELECT string_agg(url, ' ' ORDER BY url) FROM cve_nvd_reference WHERE cve_nvd_reference.cve_nvd_id = cve_nvd.id) AS hyperlinks FROM cve_nvd WHERE cve_nvd.id IN (SELECT cve_nvd_id FROM cve_nvd_vulnerability WHERE vulnerability_id IN (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)) ORDER BY cve_id", identifier)
         cve_opencve_data = await fetch_from_db(
-            "SELECT * FROM cve_opencve WHERE vulnerability_id = (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
+            "SELECT * FROM cve_opencve WHERE cve_id = $1", identifier)
         only_cve = await fetch_from_db(
-            "SELECT link FROM cve_identifier WHERE vulnerability_id = (SELECT vulnerability_id FROM cve_identifier WHERE link = $1)", identifier)
-        return vulnerability_data, software_data, os_data, cve_nvd_data, cve_opencve_data, only_cve
+            "SELECT DISTINCT link FROM cve_identifier WHERE vulnerability_id IN (SELECT vulnerability_id FROM cve_identifier WHERE link = $1) ORDER BY link", identifier)
+        ubi_data = await fetch_from_db(
+            "SELECT DISTINCT ubi.threat_id, ubi.name FROM vulnerability_ubi JOIN ubi ON ubi.id = vulnerability_ubi.ubi_id WHERE vulnerability_ubi.vulnerability_id IN (SELECT vulnerability_id FROM cve_identifier WHERE link = $1) ORDER BY ubi.threat_id", identifier)
+        return vulnerability_data, software_data, os_data, cve_nvd_data, cve_opencve_data, only_cve, ubi_data
     except Exception as e:
         logging.error(f"Произошла ошибка при поиске по CVE: {e}")
         return None
@@ -149,14 +153,188 @@ async def get_ubi(id):
     """
     Выполняет поиск информации по УБИ.
 
-    :param id: идентификатор УБИ
+    :param id: номер УБИ
     :return: данные по УБИ или None в случае ошибки
     """
     logging.info(f"Поиск УБИ: {id}")
     try:
         ubi_inf = await fetch_from_db(
-            "SELECT * FROM ubi WHERE id = $1", id)
+            "SELECT * FROM ubi WHERE threat_id = $1 AND NOT removed", id)
         return ubi_inf
     except Exception as e:
         logging.error(f"Произошла ошибка при извлечении данных по УБИ: {e}")
         return None
+
+
+async def search_by_vendor(vendor):
+    """
+    Выполняет поиск уязвимостей БДУ по производителю из NVD и OpenCVE.
+
+    :param vendor: имя производителя в терминах CPE, например microsoft
+    :return: идентификаторы и названия уязвимостей или None в случае ошибки
+    """
+    logging.info(f"Поиск по производителю: {vendor}")
+    try:
+        vulnerabilities = await fetch_from_db(
+            """
+            SELECT v.identifier, v.name
+            FROM vulnerability_vendor vv
+            JOIN vulnerability v ON v.id = vv.vulnerability_id
+            WHERE vv.vendor = $1
+            ORDER BY v.identifier DESC
+            """, vendor)
+        return vulnerabilities
+    except Exception as e:
+        logging.error(f"Произошла ошибка при поиске по производителю: {e}")
+        return None
+
+
+async def execute_in_db(query, *params):
+    """
+    Выполняет запрос на изменение данных.
+
+    :param query: SQL запрос
+    :param params: параметры для SQL запроса
+    :return: True при успешном выполнении, False в случае ошибки
+    """
+    conn = await asyncpg.connect(user=USER, password=PASSWORD, database=DATABASE, host=HOST, port=PORT)
+    try:
+        await conn.execute(query, *params)
+        return True
+    except Exception as e:
+        logging.error(f"Ошибка при выполнении запроса: {e}")
+        return False
+    finally:
+        await conn.close()
+
+
+async def create_subscription_tables():
+    """
+    Создает таблицы подписок на производителей и уже отправленных уведомлений.
+
+    :return: True при успешном создании, False в случае ошибки
+    """
+    logging.info("Создание таблиц подписок")
+    return await execute_in_db(
+        """
+        CREATE TABLE IF NOT EXISTS vendor_subscription (
+            chat_id BIGINT NOT NULL,
+            vendor TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT now(),
+            PRIMARY KEY (chat_id, vendor)
+        );
+        CREATE TABLE IF NOT EXISTS vendor_subscription_notified (
+            chat_id BIGINT NOT NULL,
+            vendor TEXT NOT NULL,
+            vulnerability_id INTEGER NOT NULL,
+            notified_at TIMESTAMP NOT NULL DEFAULT now(),
+            PRIMARY KEY (chat_id, vendor, vulnerability_id),
+            FOREIGN KEY (chat_id, vendor) REFERENCES vendor_subscription (chat_id, vendor) ON DELETE CASCADE
+        );
+        """)
+
+
+async def subscribe_vendor(chat_id, vendor):
+    """
+    Подписывает чат на новые уязвимости производителя. Уже связанные с производителем
+    уязвимости отмечаются отправленными, чтобы уведомления приходили только о новых.
+
+    :param chat_id: идентификатор чата Telegram
+    :param vendor: имя производителя в терминах CPE
+    :return: True, если подписка создана, False, если она уже была, None в случае ошибки
+    """
+    logging.info(f"Подписка чата {chat_id} на производителя: {vendor}")
+    conn = await asyncpg.connect(user=USER, password=PASSWORD, database=DATABASE, host=HOST, port=PORT)
+    try:
+        async with conn.transaction():
+            status = await conn.execute(
+                "INSERT INTO vendor_subscription (chat_id, vendor) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+                chat_id, vendor)
+            if status.endswith(" 0"):
+                return False
+            await conn.execute(
+                """
+                INSERT INTO vendor_subscription_notified (chat_id, vendor, vulnerability_id)
+                SELECT $1, $2, vulnerability_id FROM vulnerability_vendor WHERE vendor = $2
+                ON CONFLICT DO NOTHING
+                """, chat_id, vendor)
+        return True
+    except Exception as e:
+        logging.error(f"Произошла ошибка при подписке на производителя: {e}")
+        return None
+    finally:
+        await conn.close()
+
+
+async def unsubscribe_vendor(chat_id, vendor):
+    """
+    Отменяет подписку чата на производителя.
+
+    :param chat_id: идентификатор чата Telegram
+    :param vendor: имя производителя в терминах CPE
+    :return: True при успешном выполнении, False в случае ошибки
+    """
+    logging.info(f"Отмена подписки чата {chat_id} на производителя: {vendor}")
+    return await execute_in_db(
+        "DELETE FROM vendor_subscription WHERE chat_id = $1 AND vendor = $2", chat_id, vendor)
+
+
+async def unsubscribe_chat(chat_id):
+    """
+    Отменяет все подписки чата, например если бот заблокирован пользователем.
+
+    :param chat_id: идентификатор чата Telegram
+    :return: True при успешном выполнении, False в случае ошибки
+    """
+    logging.info(f"Отмена всех подписок чата {chat_id}")
+    return await execute_in_db("DELETE FROM vendor_subscription WHERE chat_id = $1", chat_id)
+
+
+async def get_subscriptions(chat_id):
+    """
+    Запрашивает производителей, на которых подписан чат.
+
+    :param chat_id: идентификатор чата Telegram
+    :return: производители или None в случае ошибки
+    """
+    logging.info(f"Запрос подписок чата {chat_id}")
+    return await fetch_from_db(
+        "SELECT vendor FROM vendor_subscription WHERE chat_id = $1 ORDER BY vendor", chat_id)
+
+
+async def get_new_vendor_links():
+    """
+    Запрашивает связи уязвимостей БДУ с производителями, появившиеся после подписки
+    и еще не отправленные подписчикам.
+
+    :return: чаты, производители и уязвимости или None в случае ошибки
+    """
+    logging.info("Запрос новых уязвимостей по подпискам")
+    return await fetch_from_db(
+        """
+        SELECT vs.chat_id, vs.vendor, v.id AS vulnerability_id, v.identifier, v.name
+        FROM vendor_subscription vs
+        JOIN vulnerability_vendor vv ON vv.vendor = vs.vendor
+        JOIN vulnerability v ON v.id = vv.vulnerability_id
+        LEFT JOIN vendor_subscription_notified n
+            ON n.chat_id = vs.chat_id AND n.vendor = vs.vendor AND n.vulnerability_id = v.id
+        WHERE n.vulnerability_id IS NULL
+        ORDER BY vs.chat_id, vs.vendor, v.identifier
+        """)
+
+
+async def mark_vendor_links_notified(chat_id, vendor, vulnerability_ids):
+    """
+    Отмечает уязвимости производителя отправленными в чат.
+
+    :param chat_id: идентификатор чата Telegram
+    :param vendor: имя производителя в терминах CPE
+    :param vulnerability_ids: идентификаторы уязвимостей
+    :return: True при успешном выполнении, False в случае ошибки
+    """
+    return await execute_in_db(
+        """
+        INSERT INTO vendor_subscription_notified (chat_id, vendor, vulnerability_id)
+        SELECT $1, $2, unnest($3::INTEGER[])
+        ON CONFLICT DO NOTHING
+        """, chat_id, vendor, vulnerability_ids)
@@ -0,0 +1,48 @@
+// Package cveid извлекает идентификаторы CVE из записей БДУ и NVD и приводит их
+// к каноническому виду CVE-YYYY-NNNN, который служит ключом во всех таблицах.
+package cveid
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Идентификатор CVE в произвольной записи: cve-2021-44228, CVE 2021 44228,
+// ссылка https://nvd.nist.gov/vuln/detail/CVE-2021-44228 и т.п.
+var pattern = regexp.MustCompile(`(?i)CVE[\s_\x{2010}-\x{2014}-]*(\d{4})[\s_\x{2010}-\x{2014}-]*(\d{4,})`)
+
+// Parse возвращает идентификаторы CVE из строки в каноническом виде без повторов.
+// Строка может содержать несколько идентификаторов, пробелы и ссылки; идентификаторы
+// с годом раньше 1999 или нулевым номером отбрасываются.
+func Parse(s string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, match := range pattern.FindAllStringSubmatch(s, -1) {
+		id, ok := canonical(match[1], match[2])
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Функция для проверки года и порядкового номера CVE и приведения их к каноническому виду.
+// Номер содержит не менее четырех цифр, ведущие нули сверх четырех цифр отбрасываются.
+func canonical(year, number string) (string, bool) {
+	y, err := strconv.Atoi(year)
+	if err != nil || y < 1999 {
+		return "", false
+	}
+	number = strings.TrimLeft(number, "0")
+	if number == "" {
+		return "", false
+	}
+	if len(number) < 4 {
+		number = strings.Repeat("0", 4-len(number)) + number
+	}
+	return fmt.Sprintf("CVE-%d-%s", y, number), true
+}
@@ -0,0 +1,44 @@
+package cveid
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"канонический", "CVE-2021-44228", []string{"CVE-2021-44228"}},
+		{"пробелы по краям", "  CVE-2021-44228\t", []string{"CVE-2021-44228"}},
+		{"пробелы вместо дефисов", "CVE 2021 44228", []string{"CVE-2021-44228"}},
+		{"подчеркивания", "CVE_2021_44228", []string{"CVE-2021-44228"}},
+		{"нижний регистр", "cve-2021-44228", []string{"CVE-2021-44228"}},
+		{"типографское тире", "CVE–2021—44228", []string{"CVE-2021-44228"}},
+		{"несколько в одной ячейке", "CVE-2021-44228, cve-2021-45046; CVE 2021 45105",
+			[]string{"CVE-2021-44228", "CVE-2021-45046", "CVE-2021-45105"}},
+		{"повторы", "CVE-2021-44228 cve-2021-44228", []string{"CVE-2021-44228"}},
+		{"ссылка NVD", "https://nvd.nist.gov/vuln/detail/CVE-2021-44228", []string{"CVE-2021-44228"}},
+		{"ссылка MITRE", "https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-2014-0160", []string{"CVE-2014-0160"}},
+		{"ссылка cve.org", "https://www.cve.org/CVERecord?id=CVE-2024-3094", []string{"CVE-2024-3094"}},
+		{"четырехзначный номер", "CVE-2014-0160", []string{"CVE-2014-0160"}},
+		{"лишние ведущие нули", "CVE-2014-000160", []string{"CVE-2014-0160"}},
+		{"семизначный номер", "CVE-2021-1000001", []string{"CVE-2021-1000001"}},
+		{"короткий номер", "CVE-2021-123", nil},
+		{"нулевой номер", "CVE-2021-0000", nil},
+		{"год до 1999", "CVE-1998-1234", nil},
+		{"короткий год", "CVE-21-44228", nil},
+		{"без префикса", "2021-44228", nil},
+		{"пустая строка", "", nil},
+		{"идентификатор БДУ", "BDU:2021-05969", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
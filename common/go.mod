@@ -0,0 +1,5 @@
+module vkr_common
+
+go 1.16
+
+require golang.org/x/text v0.14.0
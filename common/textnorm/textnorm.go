@@ -0,0 +1,48 @@
+// Package textnorm приводит текст из выгрузок ФСТЭК (XML БДУ, thrlist.xlsx, CSV) к единому виду
+// перед сохранением в базу данных.
+package textnorm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Экранированные символы OOXML вида _x000D_, которые попадают в текст из выгрузок Excel
+var ooxmlEscape = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+// Normalize раскрывает _xHHHH_, приводит текст к Unicode NFC, удаляет управляющие символы и схлопывает
+// пробелы, включая неразрывные. Переводы строк приводятся к "\n", пустые строки удаляются.
+func Normalize(s string) string {
+	s = ooxmlEscape.ReplaceAllStringFunc(s, func(escaped string) string {
+		code, err := strconv.ParseUint(escaped[2:6], 16, 32)
+		if err != nil {
+			return escaped
+		}
+		return string(rune(code))
+	})
+	s = norm.NFC.String(s)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return ' '
+			}
+			if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+				return -1
+			}
+			return r
+		}, line)
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
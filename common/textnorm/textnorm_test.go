@@ -0,0 +1,36 @@
+package textnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"пустая строка", "", ""},
+		{"без изменений", "Угроза УБИ.001", "Угроза УБИ.001"},
+		{"_x000D_ перед переводом строки", "первая_x000D_\nвторая", "первая\nвторая"},
+		{"_x000D_ без перевода строки", "первая_x000D_вторая", "первая\nвторая"},
+		{"_x0009_ как табуляция", "a_x0009_b", "a b"},
+		{"некорректное экранирование сохраняется", "_xZZZZ_", "_xZZZZ_"},
+		{"неразрывный пробел", "10\u00a0000", "10 000"},
+		{"узкий неразрывный пробел", "a\u202fb", "a b"},
+		{"NFC: и с комбинируемым бревисом", "\u0438\u0306", "\u0439"},
+		{"NFC: латиница", "e\u0301", "\u00e9"},
+		{"управляющие символы", "a\x00b\x07c\x1b", "abc"},
+		{"символы форматирования", "a\u200bb\ufeffc\u00add", "abcd"},
+		{"пробелы схлопываются", "  a \t  b  ", "a b"},
+		{"CRLF", "a\r\nb", "a\nb"},
+		{"CR", "a\rb", "a\nb"},
+		{"пустые строки удаляются", "a\n\n  \n \nb\n", "a\nb"},
+		{"пробелы по краям строк", " a \n  b ", "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
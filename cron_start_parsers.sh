@@ -19,18 +19,39 @@ while [[ $(docker inspect -f '{{.State.Status}}' parser_xlsx) == "running" ]]; d
     sleep 5
 done
 
-docker compose start parser_nvd 
-docker compose start parser_opencve
-# Ожидание завершения работы parser_nvd и parser_opencve с таймаутом 30 минут
+# Обогащение данными каталога CISA KEV
+docker compose start parser_kev
+# Ожидание завершения работы parser_kev
+while [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_kev-1) == "true" ]]; do
+    sleep 5
+done
+
+# Обогащение оценками EPSS
+docker compose start parser_epss
+# Ожидание завершения работы parser_epss
+while [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_epss-1) == "true" ]]; do
+    sleep 5
+done
+
+# parser_opencve берет векторы CVSS и продукты из таблиц NVD, поэтому запускается после parser_nvd
+docker compose start parser_nvd
+# Ожидание завершения работы parser_nvd с таймаутом 30 минут
 END=$((SECONDS+1800))
-while [[ $SECONDS -lt $END ]]; do
-    if [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_nvd-1) != "true" ]] && [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_opencve-1) != "true" ]]; then
-        break
-    fi
+while [[ $SECONDS -lt $END ]] && [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_nvd-1) == "true" ]]; do
     sleep 5
 done
+# Остановка parser_nvd, если он все еще запущен после 30 минут
+if [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_nvd-1) == "true" ]]; then
+    docker compose stop parser_nvd
+fi
 
-# Остановка контейнеров, если они все еще запущены после 30 минут
-if [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_nvd-1) == "true" ]] || [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_opencve-1) == "true" ]]; then
-    docker compose stop parser_nvd parser_opencve
-fi
\ No newline at end of file
+docker compose start parser_opencve
+# Ожидание завершения работы parser_opencve с таймаутом 30 минут
+END=$((SECONDS+1800))
+while [[ $SECONDS -lt $END ]] && [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_opencve-1) == "true" ]]; do
+    sleep 5
+done
+# Остановка parser_opencve, если он все еще запущен после 30 минут
+if [[ $(docker inspect --format '{{.State.Running}}' test_vkr-parser_opencve-1) == "true" ]]; then
+    docker compose stop parser_opencve
+fi
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+const (
+	// Ежедневная выгрузка оценок EPSS от FIRST
+	epssURL = "https://epss.cyentia.com/epss_scores-current.csv.gz"
+	// Время ожидания загрузки выгрузки
+	epssRequestTimeout = 5 * time.Minute
+	// Формат даты оценки в заголовке выгрузки EPSS
+	epssDateLayout = "2006-01-02"
+)
+
+func main() {
+	// Загрузка переменных окружения из .env файла, который находится в поддиректории
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки .env файла: %v", err)
+	}
+
+	// Получение параметров подключения из переменных окружения
+	dbUser := os.Getenv("DB_USER")
+	dbPassword := os.Getenv("DB_PASSWORD")
+	dbHost := os.Getenv("DB_HOST")
+	dbPort := os.Getenv("DB_PORT")
+	dbName := os.Getenv("DB_DATABASE")
+
+	if dbUser == "" || dbPassword == "" || dbHost == "" || dbPort == "" || dbName == "" {
+		log.Fatalf("Параметры подключения к базе данных не заданы в .env файле")
+	}
+
+	// Формирование строки подключения
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", dbUser, dbPassword, dbHost, dbPort, dbName)
+	ctx := context.Background()
+
+	// Открытие файла для логирования
+	logFile, err := os.OpenFile("output_parser_epss.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println("Ошибка при открытии файла логов:", err)
+		return
+	}
+	defer logFile.Close()
+
+	// Настройка логгера
+	logger := log.New(logFile, "", log.LstdFlags)
+
+	// Создание пула подключений
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		logger.Fatalf("Не удалось разобрать строку подключения: %v\n", err)
+	}
+
+	dbpool, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		logger.Fatalf("Не удалось создать пул подключений: %v\n", err)
+	}
+	defer dbpool.Close()
+
+	err = createEpssTables(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы cve_epss: %v\n", err)
+	}
+
+	// Оценки сохраняются только для CVE, упомянутых в уязвимостях БДУ
+	wanted, err := fetchCVEIDs(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка при получении идентификаторов CVE: %v\n", err)
+	}
+
+	// Выгрузка загружается по адресу или из локального файла, заданного в EPSS_SOURCE
+	source := os.Getenv("EPSS_SOURCE")
+	if source == "" {
+		source = epssURL
+	}
+	logger.Printf("Загрузка оценок EPSS из %s\n", source)
+
+	feed, err := loadEPSSFeed(ctx, source, wanted)
+	if err != nil {
+		logger.Fatalf("Ошибка при загрузке оценок EPSS: %v\n", err)
+	}
+	logger.Printf("Модель EPSS %s, дата оценки %s, найдено оценок для CVE уязвимостей: %d из %d\n",
+		feed.ModelVersion, feed.ScoreDate.Format(epssDateLayout), len(feed.Scores), len(wanted))
+
+	err = saveEPSSScores(ctx, dbpool, feed)
+	if err != nil {
+		logger.Fatalf("Ошибка при сохранении оценок EPSS: %v\n", err)
+	}
+
+	logger.Println("Получение и сохранение оценок EPSS успешно завершены!")
+}
+
+// EPSSFeed - оценки EPSS на одну дату
+type EPSSFeed struct {
+	ModelVersion string
+	ScoreDate    time.Time
+	Scores       []EPSSScore
+}
+
+// EPSSScore - вероятность эксплуатации CVE в ближайшие 30 дней и ее процентиль
+type EPSSScore struct {
+	CVEID      string
+	EPSS       float64
+	Percentile float64
+}
+
+// Функция для создания таблицы истории оценок EPSS и представления с последней оценкой уязвимостей БДУ
+func createEpssTables(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cve_epss (
+			cve_id TEXT NOT NULL,
+			score_date DATE NOT NULL,
+			epss DOUBLE PRECISION NOT NULL,
+			percentile DOUBLE PRECISION NOT NULL,
+			model_version TEXT,
+			PRIMARY KEY(cve_id, score_date)
+		);`,
+		`CREATE INDEX IF NOT EXISTS cve_epss_score_date_idx ON cve_epss (score_date);`,
+		// Наибольшая оценка среди CVE уязвимости на последнюю загруженную дату
+		`CREATE OR REPLACE VIEW vulnerability_epss AS
+		SELECT DISTINCT ON (cve_identifier.vulnerability_id)
+			cve_identifier.vulnerability_id, cve_epss.cve_id, cve_epss.epss, cve_epss.percentile, cve_epss.score_date
+		FROM cve_identifier
+		JOIN cve_epss ON cve_epss.cve_id = cve_identifier.link
+		WHERE cve_epss.score_date = (SELECT max(score_date) FROM cve_epss)
+		ORDER BY cve_identifier.vulnerability_id, cve_epss.epss DESC;`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для получения множества идентификаторов CVE из таблицы cve_identifier
+func fetchCVEIDs(ctx context.Context, dbpool *pgxpool.Pool) (map[string]bool, error) {
+	rows, err := dbpool.Query(ctx, `SELECT DISTINCT link FROM cve_identifier`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cveIDs := make(map[string]bool)
+	for rows.Next() {
+		var link string
+		if err := rows.Scan(&link); err != nil {
+			return nil, err
+		}
+		cveIDs[strings.ToUpper(strings.TrimSpace(link))] = true
+	}
+	return cveIDs, rows.Err()
+}
+
+// Функция для загрузки выгрузки EPSS по адресу http(s) или из локального файла.
+// Сжатие gzip определяется по содержимому, поэтому допускается и распакованный CSV.
+func loadEPSSFeed(ctx context.Context, source string, wanted map[string]bool) (*EPSSFeed, error) {
+	var r io.Reader
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		ctx, cancel := context.WithTimeout(ctx, epssRequestTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("получен ненормативный код ответа %d для %s", res.StatusCode, source)
+		}
+		r = res.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return parseEPSSFeed(gz, wanted)
+	}
+	return parseEPSSFeed(buffered, wanted)
+}
+
+// Функция для разбора CSV EPSS. Первая строка - комментарий вида
+// #model_version:v2023.03.01,score_date:2023-03-21T00:00:00+0000, затем заголовок cve,epss,percentile.
+func parseEPSSFeed(r io.Reader, wanted map[string]bool) (*EPSSFeed, error) {
+	buffered := bufio.NewReader(r)
+	feed := &EPSSFeed{}
+
+	comment, err := buffered.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(comment, "#") {
+		return nil, fmt.Errorf("не найден заголовок выгрузки EPSS с датой оценки")
+	}
+	for _, field := range strings.Split(strings.TrimSpace(strings.TrimPrefix(comment, "#")), ",") {
+		key, value, _ := strings.Cut(field, ":")
+		switch key {
+		case "model_version":
+			feed.ModelVersion = value
+		case "score_date":
+			if len(value) < len(epssDateLayout) {
+				return nil, fmt.Errorf("некорректная дата оценки EPSS: %q", value)
+			}
+			feed.ScoreDate, err = time.Parse(epssDateLayout, value[:len(epssDateLayout)])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if feed.ScoreDate.IsZero() {
+		return nil, fmt.Errorf("в выгрузке EPSS не указана дата оценки")
+	}
+
+	reader := csv.NewReader(buffered)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 3 || header[0] != "cve" || header[1] != "epss" || header[2] != "percentile" {
+		return nil, fmt.Errorf("неожиданный заголовок выгрузки EPSS: %v", header)
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cveID := strings.ToUpper(strings.TrimSpace(record[0]))
+		if !wanted[cveID] {
+			continue
+		}
+		epss, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: некорректная оценка EPSS: %w", cveID, err)
+		}
+		percentile, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: некорректный процентиль EPSS: %w", cveID, err)
+		}
+		feed.Scores = append(feed.Scores, EPSSScore{CVEID: cveID, EPSS: epss, Percentile: percentile})
+	}
+	return feed, nil
+}
+
+// Функция для сохранения оценок на дату выгрузки. Оценки за предыдущие даты сохраняются как история,
+// повторная загрузка той же даты заменяет оценки этой даты.
+func saveEPSSScores(ctx context.Context, dbpool *pgxpool.Pool, feed *EPSSFeed) error {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM cve_epss WHERE score_date = $1`, feed.ScoreDate)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, 0, len(feed.Scores))
+	for _, score := range feed.Scores {
+		rows = append(rows, []interface{}{score.CVEID, feed.ScoreDate, score.EPSS, score.Percentile, feed.ModelVersion})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"cve_epss"},
+		[]string{"cve_id", "score_date", "epss", "percentile", "model_version"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+const (
+	// Каталог CISA Known Exploited Vulnerabilities
+	kevURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+	// Время ожидания загрузки каталога
+	kevRequestTimeout = 2 * time.Minute
+	// Формат дат в каталоге KEV
+	kevDateLayout = "2006-01-02"
+)
+
+func main() {
+	// Загрузка переменных окружения из .env файла, который находится в поддиректории
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки .env файла: %v", err)
+	}
+
+	// Получение параметров подключения из переменных окружения
+	dbUser := os.Getenv("DB_USER")
+	dbPassword := os.Getenv("DB_PASSWORD")
+	dbHost := os.Getenv("DB_HOST")
+	dbPort := os.Getenv("DB_PORT")
+	dbName := os.Getenv("DB_DATABASE")
+
+	if dbUser == "" || dbPassword == "" || dbHost == "" || dbPort == "" || dbName == "" {
+		log.Fatalf("Параметры подключения к базе данных не заданы в .env файле")
+	}
+
+	// Формирование строки подключения
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", dbUser, dbPassword, dbHost, dbPort, dbName)
+	ctx := context.Background()
+
+	// Открытие файла для логирования
+	logFile, err := os.OpenFile("output_parser_kev.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println("Ошибка при открытии файла логов:", err)
+		return
+	}
+	defer logFile.Close()
+
+	// Настройка логгера
+	logger := log.New(logFile, "", log.LstdFlags)
+
+	// Создание пула подключений
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		logger.Fatalf("Не удалось разобрать строку подключения: %v\n", err)
+	}
+
+	dbpool, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		logger.Fatalf("Не удалось создать пул подключений: %v\n", err)
+	}
+	defer dbpool.Close()
+
+	err = createKevTables(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы cve_kev: %v\n", err)
+	}
+
+	// Каталог загружается по адресу или из локального файла, заданного в KEV_SOURCE
+	source := os.Getenv("KEV_SOURCE")
+	if source == "" {
+		source = kevURL
+	}
+	logger.Printf("Загрузка каталога KEV из %s\n", source)
+
+	catalog, err := loadKEVCatalog(ctx, source)
+	if err != nil {
+		logger.Fatalf("Ошибка при загрузке каталога KEV: %v\n", err)
+	}
+	logger.Printf("Каталог KEV версии %s от %s, записей: %d\n", catalog.CatalogVersion, catalog.DateReleased, len(catalog.Vulnerabilities))
+
+	linked, err := saveKEVCatalog(ctx, dbpool, catalog, logger)
+	if err != nil {
+		logger.Fatalf("Ошибка при сохранении каталога KEV: %v\n", err)
+	}
+
+	logger.Printf("Уязвимостей БДУ с эксплуатируемыми CVE: %d\n", linked)
+	logger.Println("Получение и сохранение данных KEV успешно завершены!")
+}
+
+// KEVCatalog - каталог CISA Known Exploited Vulnerabilities
+type KEVCatalog struct {
+	Title           string     `json:"title"`
+	CatalogVersion  string     `json:"catalogVersion"`
+	DateReleased    string     `json:"dateReleased"`
+	Count           int        `json:"count"`
+	Vulnerabilities []KEVEntry `json:"vulnerabilities"`
+}
+
+// KEVEntry - CVE, эксплуатация которой подтверждена CISA
+type KEVEntry struct {
+	CVEID                      string `json:"cveID"`
+	VendorProject              string `json:"vendorProject"`
+	Product                    string `json:"product"`
+	VulnerabilityName          string `json:"vulnerabilityName"`
+	DateAdded                  string `json:"dateAdded"`
+	ShortDescription           string `json:"shortDescription"`
+	RequiredAction             string `json:"requiredAction"`
+	DueDate                    string `json:"dueDate"`
+	KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
+	Notes                      string `json:"notes"`
+}
+
+// Функция для создания таблицы каталога KEV и представления со связями уязвимостей БДУ
+func createKevTables(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cve_kev (
+			id SERIAL PRIMARY KEY,
+			cve_id TEXT NOT NULL UNIQUE,
+			vendor_project TEXT,
+			product TEXT,
+			vulnerability_name TEXT,
+			date_added DATE,
+			short_description TEXT,
+			required_action TEXT,
+			due_date DATE,
+			known_ransomware_campaign_use TEXT,
+			notes TEXT,
+			last_fetched TIMESTAMP
+		);`,
+		// Уязвимости БДУ, CVE которых есть в каталоге KEV
+		`CREATE OR REPLACE VIEW vulnerability_kev AS
+		SELECT cve_identifier.vulnerability_id, cve_kev.cve_id, cve_kev.date_added,
+			cve_kev.required_action, cve_kev.due_date, cve_kev.known_ransomware_campaign_use
+		FROM cve_identifier
+		JOIN cve_kev ON cve_kev.cve_id = cve_identifier.link;`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для загрузки каталога KEV по адресу http(s) или из локального файла
+func loadKEVCatalog(ctx context.Context, source string) (*KEVCatalog, error) {
+	var r io.Reader
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		ctx, cancel := context.WithTimeout(ctx, kevRequestTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("получен ненормативный код ответа %d для %s", res.StatusCode, source)
+		}
+		r = res.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var catalog KEVCatalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе JSON каталога KEV: %w", err)
+	}
+	if len(catalog.Vulnerabilities) == 0 {
+		return nil, fmt.Errorf("каталог KEV не содержит записей")
+	}
+	return &catalog, nil
+}
+
+// Функция для сохранения каталога KEV в одной транзакции: записи обновляются,
+// исключенные из каталога удаляются. Возвращает количество уязвимостей БДУ с CVE из каталога.
+func saveKEVCatalog(ctx context.Context, dbpool *pgxpool.Pool, catalog *KEVCatalog, logger *log.Logger) (int, error) {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	cveIDs := make([]string, 0, len(catalog.Vulnerabilities))
+	for _, entry := range catalog.Vulnerabilities {
+		cveID := strings.ToUpper(strings.TrimSpace(entry.CVEID))
+		if !strings.HasPrefix(cveID, "CVE-") {
+			logger.Printf("Некорректный идентификатор CVE в каталоге KEV: %q\n", entry.CVEID)
+			continue
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO cve_kev (cve_id, vendor_project, product, vulnerability_name, date_added,
+				short_description, required_action, due_date, known_ransomware_campaign_use, notes, last_fetched)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (cve_id) DO UPDATE
+			SET vendor_project = EXCLUDED.vendor_project, product = EXCLUDED.product,
+				vulnerability_name = EXCLUDED.vulnerability_name, date_added = EXCLUDED.date_added,
+				short_description = EXCLUDED.short_description, required_action = EXCLUDED.required_action,
+				due_date = EXCLUDED.due_date, known_ransomware_campaign_use = EXCLUDED.known_ransomware_campaign_use,
+				notes = EXCLUDED.notes, last_fetched = EXCLUDED.last_fetched
+		`, cveID, entry.VendorProject, entry.Product, entry.VulnerabilityName, kevDateOrNil(entry.DateAdded),
+			entry.ShortDescription, entry.RequiredAction, kevDateOrNil(entry.DueDate),
+			entry.KnownRansomwareCampaignUse, entry.Notes, now)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", cveID, err)
+		}
+		cveIDs = append(cveIDs, cveID)
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM cve_kev WHERE NOT (cve_id = ANY($1))`, cveIDs)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() > 0 {
+		logger.Printf("Удалено записей, исключенных из каталога KEV: %d\n", tag.RowsAffected())
+	}
+
+	var linked int
+	err = tx.QueryRow(ctx, `SELECT COUNT(DISTINCT vulnerability_id) FROM vulnerability_kev`).Scan(&linked)
+	if err != nil {
+		return 0, err
+	}
+	return linked, tx.Commit(ctx)
+}
+
+// Функция для преобразования даты каталога KEV для записи в базу: nil, если дата не задана
+func kevDateOrNil(value string) *time.Time {
+	t, err := time.Parse(kevDateLayout, strings.TrimSpace(value))
+	if err != nil {
+		return nil
+	}
+	return &t
+}
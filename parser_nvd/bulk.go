@@ -0,0 +1,338 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// bulkResult - итог загрузки из выгрузки NVD
+type bulkResult struct {
+	FeedTime time.Time // время последнего изменения CVE в обработанных файлах
+	Complete bool      // все файлы выгрузки обработаны без ошибок
+}
+
+// Функция для создания таблицы файлов выгрузки NVD, полностью загруженных в cve_nvd
+func createBulkFileTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	_, err := dbpool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS nvd_bulk_file (
+			file TEXT PRIMARY KEY,
+			feed_time TIMESTAMPTZ,
+			imported_at TIMESTAMPTZ NOT NULL
+		);
+	`)
+	return err
+}
+
+// Функция для первичной загрузки CVE из файлов выгрузки NVD вместо запросов к API по одной CVE.
+// path - файл или каталог с файлами в формате NVD CVE API 2.0: годовые выгрузки
+// nvdcve-2.0-YYYY.json(.gz), zip-архив зеркала или отдельные файлы CVE-YYYY-NNNN.json.
+// Сохраняются только CVE, упомянутые в cve_identifier. Файл, все CVE которого сохранены,
+// отмечается в nvd_bulk_file и при следующих запусках пропускается, поэтому загрузка,
+// прерванная по истечении времени работы (fetchCtx), продолжается со следующего файла.
+func importBulkFeeds(ctx, fetchCtx context.Context, dbpool *pgxpool.Pool, path string, logger *log.Logger) (bulkResult, error) {
+	cveIDs, err := fetchDistinctCVEIDs(ctx, dbpool)
+	if err != nil {
+		return bulkResult{}, err
+	}
+	wanted := make(map[string]bool, len(cveIDs))
+	for _, cveID := range cveIDs {
+		wanted[cveID] = true
+	}
+	known, err := fetchKnownCVEs(ctx, dbpool)
+	if err != nil {
+		return bulkResult{}, err
+	}
+
+	files, err := bulkFeedFiles(path)
+	if err != nil {
+		return bulkResult{}, err
+	}
+	if len(files) == 0 {
+		return bulkResult{}, fmt.Errorf("в %s не найдены файлы выгрузки NVD", path)
+	}
+	imported, err := loadBulkFiles(ctx, dbpool)
+	if err != nil {
+		return bulkResult{}, err
+	}
+
+	result := bulkResult{Complete: true}
+	var saved, unchanged int
+	for _, file := range files {
+		name := bulkFileName(path, file)
+		if feedTime, ok := imported[name]; ok {
+			if feedTime.After(result.FeedTime) {
+				result.FeedTime = feedTime
+			}
+			continue
+		}
+		if fetchCtx.Err() != nil {
+			result.Complete = false
+			break
+		}
+
+		logger.Printf("Загрузка выгрузки NVD: %s\n", file)
+		var feedTime time.Time
+		var failed []string
+		err := readBulkFile(file, func(cveItem CVEItem) error {
+			if err := fetchCtx.Err(); err != nil {
+				return err
+			}
+			modified := nvdTimeOrNil(cveItem.LastModified)
+			if modified != nil && modified.After(feedTime) {
+				feedTime = *modified
+			}
+			cveID := strings.ToUpper(cveItem.ID)
+			if !wanted[cveID] {
+				return nil
+			}
+			if lastModified, ok := known[cveID]; ok && modified != nil && modified.Equal(lastModified) {
+				unchanged++
+				return nil
+			}
+			if err := saveCVEDetails(ctx, dbpool, cveID, &cveItem, logger); err != nil {
+				failed = append(failed, cveID)
+				return nil
+			}
+			saved++
+			return nil
+		})
+
+		switch {
+		case err != nil && fetchCtx.Err() != nil:
+			logger.Printf("Время работы истекло, загрузка %s продолжится при следующем запуске\n", file)
+			result.Complete = false
+		case err != nil:
+			logger.Printf("Ошибка при чтении выгрузки NVD %s: %v\n", file, err)
+			result.Complete = false
+		case len(failed) > 0:
+			logger.Printf("Не удалось сохранить CVE из %s (%d): %s\n", file, len(failed), strings.Join(failed, ", "))
+			result.Complete = false
+		default:
+			if err := saveBulkFile(ctx, dbpool, name, feedTime); err != nil {
+				return result, err
+			}
+			if feedTime.After(result.FeedTime) {
+				result.FeedTime = feedTime
+			}
+		}
+	}
+
+	logger.Printf("Из выгрузки NVD сохранено CVE: %d, без изменений: %d, упомянуто в уязвимостях: %d\n", saved, unchanged, len(wanted))
+	return result, nil
+}
+
+// Функция для получения имени файла выгрузки относительно NVD_BULK_PATH, под которым он отмечается в nvd_bulk_file
+func bulkFileName(root, file string) string {
+	if rel, err := filepath.Rel(root, file); err == nil && rel != "." {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(file)
+}
+
+// Функция для получения загруженных файлов выгрузки со временем последнего изменения CVE в них
+func loadBulkFiles(ctx context.Context, dbpool *pgxpool.Pool) (map[string]time.Time, error) {
+	rows, err := dbpool.Query(ctx, `SELECT file, feed_time FROM nvd_bulk_file`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imported := make(map[string]time.Time)
+	for rows.Next() {
+		var file string
+		var feedTime *time.Time
+		if err := rows.Scan(&file, &feedTime); err != nil {
+			return nil, err
+		}
+		imported[file] = time.Time{}
+		if feedTime != nil {
+			imported[file] = *feedTime
+		}
+	}
+	return imported, rows.Err()
+}
+
+// Функция для отметки файла выгрузки как полностью загруженного
+func saveBulkFile(ctx context.Context, dbpool *pgxpool.Pool, file string, feedTime time.Time) error {
+	var value *time.Time
+	if !feedTime.IsZero() {
+		value = &feedTime
+	}
+	_, err := dbpool.Exec(ctx, `
+		INSERT INTO nvd_bulk_file (file, feed_time, imported_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (file) DO UPDATE SET feed_time = EXCLUDED.feed_time, imported_at = EXCLUDED.imported_at;
+	`, file, value)
+	return err
+}
+
+// Функция для получения списка файлов выгрузки в порядке имен
+func bulkFeedFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isBulkFeedName(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Функция для проверки, что файл похож на выгрузку NVD
+func isBulkFeedName(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz") || strings.HasSuffix(name, ".zip")
+}
+
+// Функция для чтения одного файла выгрузки: json, json.gz или zip-архива с такими файлами
+func readBulkFile(path string, fn func(CVEItem) error) error {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() || !isBulkFeedName(entry.Name) || strings.HasSuffix(strings.ToLower(entry.Name), ".zip") {
+				continue
+			}
+			r, err := entry.Open()
+			if err != nil {
+				return err
+			}
+			err = readBulkStream(r, entry.Name, fn)
+			r.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.Name, err)
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readBulkStream(f, path, fn)
+}
+
+// Функция для распаковки gzip по имени файла и разбора JSON
+func readBulkStream(r io.Reader, name string, fn func(CVEItem) error) error {
+	if strings.HasSuffix(strings.ToLower(name), ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return decodeBulkJSON(r, fn)
+}
+
+// Функция для потокового разбора JSON выгрузки. Массив vulnerabilities годовых выгрузок
+// разбирается по одной записи, не загружая файл в память целиком; файл с одной CVE
+// (объект с полем id на верхнем уровне) разбирается как отдельная запись.
+func decodeBulkJSON(r io.Reader, fn func(CVEItem) error) error {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	single := make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		if key != "vulnerabilities" {
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return err
+			}
+			single[key] = value
+			continue
+		}
+
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var item struct {
+				CVE CVEItem `json:"cve"`
+			}
+			if err := decoder.Decode(&item); err != nil {
+				return err
+			}
+			if err := fn(item.CVE); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := single["id"]; !ok {
+		return nil
+	}
+	data, err := json.Marshal(single)
+	if err != nil {
+		return err
+	}
+	var cveItem CVEItem
+	if err := json.Unmarshal(data, &cveItem); err != nil {
+		return err
+	}
+	return fn(cveItem)
+}
+
+// Функция для чтения ожидаемого разделителя JSON
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("ожидался %q, получено %v", delim, token)
+	}
+	return nil
+}
+
+// Функция для разбора даты NVD, которая передается без часового пояса и считается UTC
+func parseNVDTime(value string) (time.Time, error) {
+	for _, layout := range []string{nvdDateLayout, "2006-01-02T15:04:05", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("некорректная дата NVD: %q", value)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var (
+	feedFile   = filepath.Join("testdata", "feeds", "nvdcve-2.0-2021.json")
+	singleFile = filepath.Join("testdata", "feeds", "CVE-2014-0160.json")
+	feedIDs    = []string{"CVE-2021-44228", "CVE-2021-45046"}
+	singleIDs  = []string{"CVE-2014-0160"}
+)
+
+// Функция для чтения идентификаторов всех CVE файла выгрузки
+func readBulkIDs(t *testing.T, path string) []string {
+	t.Helper()
+	var ids []string
+	err := readBulkFile(path, func(cveItem CVEItem) error {
+		ids = append(ids, cveItem.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readBulkFile(%s): %v", path, err)
+	}
+	return ids
+}
+
+// Функция для создания gzip-копии файла
+func gzipFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Функция для создания zip-архива из файлов (имя в архиве - содержимое)
+func zipFiles(t *testing.T, dst string, entries map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, data := range entries {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeBulkJSON(t *testing.T) {
+	data, err := os.ReadFile(feedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []CVEItem
+	err = decodeBulkJSON(strings.NewReader(string(data)), func(cveItem CVEItem) error {
+		items = append(items, cveItem)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decodeBulkJSON: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d CVE, want 2", len(items))
+	}
+	second := items[1]
+	if second.ID != "CVE-2021-45046" || second.LastModified != "2023-11-07T03:39:36.747" || second.VulnStatus != "Modified" {
+		t.Errorf("got %+v", second)
+	}
+	if !strings.HasPrefix(second.Description(), "It was found") {
+		t.Errorf("Description() = %q, want english description", second.Description())
+	}
+}
+
+func TestDecodeBulkJSONErrors(t *testing.T) {
+	errStop := errors.New("stop")
+	tests := []struct {
+		name string
+		in   string
+		fn   func(CVEItem) error
+	}{
+		{"not object", `[{"cve": {"id": "CVE-2021-44228"}}]`, nil},
+		{"truncated", `{"vulnerabilities": [{"cve": {"id": "CVE-2021-44228"}}`, nil},
+		{"vulnerabilities not array", `{"vulnerabilities": {}}`, nil},
+		{"callback error", `{"vulnerabilities": [{"cve": {"id": "CVE-2021-44228"}}]}`, func(CVEItem) error { return errStop }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := tt.fn
+			if fn == nil {
+				fn = func(CVEItem) error { return nil }
+			}
+			if err := decodeBulkJSON(strings.NewReader(tt.in), fn); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestDecodeBulkJSONWithoutCVE(t *testing.T) {
+	called := false
+	err := decodeBulkJSON(strings.NewReader(`{"format": "NVD_CVE", "vulnerabilities": []}`), func(CVEItem) error {
+		called = true
+		return nil
+	})
+	if err != nil || called {
+		t.Errorf("got err %v, called %v, want no CVE", err, called)
+	}
+}
+
+func TestReadBulkFile(t *testing.T) {
+	dir := t.TempDir()
+	gzPath := filepath.Join(dir, "nvdcve-2.0-2021.json.gz")
+	gzipFile(t, feedFile, gzPath)
+
+	feed, err := os.ReadFile(feedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := os.ReadFile(singleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzFeed, err := os.ReadFile(gzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipPath := filepath.Join(dir, "nvd.zip")
+	zipFiles(t, zipPath, map[string][]byte{
+		"cves/CVE-2014-0160.json":   single,
+		"nvdcve-2.0-2021.json.gz":   gzFeed,
+		"README.md":                 []byte("не выгрузка NVD"),
+		"nested/nvdcve-2.0-old.zip": []byte("вложенные архивы не читаются"),
+	})
+	zipFeedPath := filepath.Join(dir, "nvdcve-2.0-2021.json.zip")
+	zipFiles(t, zipFeedPath, map[string][]byte{"nvdcve-2.0-2021.json": feed})
+
+	tests := []struct {
+		name      string
+		path      string
+		want      []string
+		unordered bool // порядок записей в собранном тестом архиве не задан
+	}{
+		{"json", feedFile, feedIDs, false},
+		{"json.gz", gzPath, feedIDs, false},
+		{"zip", zipFeedPath, feedIDs, false},
+		{"zip mirror", zipPath, []string{"CVE-2014-0160", "CVE-2021-44228", "CVE-2021-45046"}, true},
+		{"single CVE", singleFile, singleIDs, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readBulkIDs(t, tt.path)
+			if tt.unordered {
+				sort.Strings(got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBulkFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	badGz := filepath.Join(dir, "nvdcve-2.0-2021.json.gz")
+	if err := os.WriteFile(badGz, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	badZip := filepath.Join(dir, "nvd.zip")
+	zipFiles(t, badZip, map[string][]byte{"nvdcve-2.0-2021.json": []byte(`{"vulnerabilities": [`)})
+
+	for _, path := range []string{badGz, badZip, filepath.Join(dir, "missing.json")} {
+		err := readBulkFile(path, func(CVEItem) error { return nil })
+		if err == nil {
+			t.Errorf("readBulkFile(%s): want error", path)
+		}
+	}
+
+	// ошибка обработчика прерывает чтение
+	errStop := errors.New("stop")
+	calls := 0
+	err := readBulkFile(feedFile, func(CVEItem) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("got err %v after %d calls, want errStop after 1", err, calls)
+	}
+}
+
+func TestBulkFeedFiles(t *testing.T) {
+	root := filepath.Join("testdata", "feeds")
+	files, err := bulkFeedFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{singleFile, feedFile}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("bulkFeedFiles(%s) = %v, want %v", root, files, want)
+	}
+
+	files, err = bulkFeedFiles(feedFile)
+	if err != nil || !reflect.DeepEqual(files, []string{feedFile}) {
+		t.Errorf("bulkFeedFiles(%s) = %v, %v, want the file itself", feedFile, files, err)
+	}
+
+	if _, err := bulkFeedFiles(filepath.Join(root, "missing")); err == nil {
+		t.Error("want error for missing path")
+	}
+}
+
+func TestBulkFileName(t *testing.T) {
+	tests := []struct {
+		root, file, want string
+	}{
+		{"nvd_feeds", filepath.Join("nvd_feeds", "nvdcve-2.0-2021.json.gz"), "nvdcve-2.0-2021.json.gz"},
+		{"nvd_feeds", filepath.Join("nvd_feeds", "2021", "CVE-2021-44228.json"), "2021/CVE-2021-44228.json"},
+		{filepath.Join("nvd_feeds", "nvd.zip"), filepath.Join("nvd_feeds", "nvd.zip"), "nvd.zip"},
+	}
+	for _, tt := range tests {
+		if got := bulkFileName(tt.root, tt.file); got != tt.want {
+			t.Errorf("bulkFileName(%q, %q) = %q, want %q", tt.root, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestParseNVDTime(t *testing.T) {
+	for _, value := range []string{"2024-04-03T17:15:06.647", "2024-04-03T17:15:06", "2024-04-03T17:15:06.647Z"} {
+		got, err := parseNVDTime(value)
+		if err != nil {
+			t.Errorf("parseNVDTime(%q): %v", value, err)
+			continue
+		}
+		if got.Year() != 2024 || got.Hour() != 17 || got.Location().String() != "UTC" {
+			t.Errorf("parseNVDTime(%q) = %v", value, got)
+		}
+	}
+	if _, err := parseNVDTime("03.04.2024"); err == nil {
+		t.Error("want error for unknown layout")
+	}
+	if nvdTimeOrNil("") != nil {
+		t.Error("nvdTimeOrNil(\"\") want nil")
+	}
+}
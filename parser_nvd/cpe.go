@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CVEConfiguration - конфигурация уязвимого ПО из NVD: узлы, объединенные оператором AND/OR
+type CVEConfiguration struct {
+	Operator string    `json:"operator"`
+	Negate   bool      `json:"negate"`
+	Nodes    []CPENode `json:"nodes"`
+}
+
+type CPENode struct {
+	Operator string     `json:"operator"`
+	Negate   bool       `json:"negate"`
+	CPEMatch []CPEMatch `json:"cpeMatch"`
+}
+
+// CPEMatch - критерий CPE с необязательным диапазоном уязвимых версий
+type CPEMatch struct {
+	Vulnerable            bool   `json:"vulnerable"`
+	Criteria              string `json:"criteria"`
+	MatchCriteriaID       string `json:"matchCriteriaId"`
+	VersionStartIncluding string `json:"versionStartIncluding"`
+	VersionStartExcluding string `json:"versionStartExcluding"`
+	VersionEndIncluding   string `json:"versionEndIncluding"`
+	VersionEndExcluding   string `json:"versionEndExcluding"`
+}
+
+// CPEName - основные поля имени CPE 2.3
+type CPEName struct {
+	Part    string
+	Vendor  string
+	Product string
+	Version string
+}
+
+// Функция для разбора строки CPE 2.3 (cpe:2.3:part:vendor:product:version:...) с учетом экранирования "\:"
+func parseCPE(criteria string) CPEName {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+	for _, r := range criteria {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	fields = append(fields, field.String())
+
+	var name CPEName
+	if len(fields) < 6 || fields[0] != "cpe" || fields[1] != "2.3" {
+		return name
+	}
+	name.Part, name.Vendor, name.Product, name.Version = fields[2], fields[3], fields[4], fields[5]
+	return name
+}
+
+// Функция для создания таблиц конфигураций CPE из NVD
+func createCveNvdCpeTables(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cve_nvd_cpe_config (
+			id SERIAL PRIMARY KEY,
+			cve_nvd_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			operator TEXT,
+			negate BOOLEAN NOT NULL DEFAULT FALSE,
+			FOREIGN KEY(cve_nvd_id) REFERENCES cve_nvd(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS cve_nvd_cpe_node (
+			id SERIAL PRIMARY KEY,
+			config_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			operator TEXT,
+			negate BOOLEAN NOT NULL DEFAULT FALSE,
+			FOREIGN KEY(config_id) REFERENCES cve_nvd_cpe_config(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS cve_nvd_cpe_match (
+			id SERIAL PRIMARY KEY,
+			node_id INTEGER NOT NULL,
+			vulnerable BOOLEAN NOT NULL,
+			criteria TEXT NOT NULL,
+			match_criteria_id TEXT,
+			part TEXT,
+			vendor TEXT,
+			product TEXT,
+			version TEXT,
+			version_start_including TEXT,
+			version_start_excluding TEXT,
+			version_end_including TEXT,
+			version_end_excluding TEXT,
+			FOREIGN KEY(node_id) REFERENCES cve_nvd_cpe_node(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS cve_nvd_cpe_match_product_idx ON cve_nvd_cpe_match(vendor, product);`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для замены дерева конфигураций CPE записи cve_nvd
+func saveCVEConfigurations(ctx context.Context, tx pgx.Tx, cveNvdID int, configurations []CVEConfiguration) error {
+	_, err := tx.Exec(ctx, `DELETE FROM cve_nvd_cpe_config WHERE cve_nvd_id = $1`, cveNvdID)
+	if err != nil {
+		return err
+	}
+
+	for i, config := range configurations {
+		var configID int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO cve_nvd_cpe_config (cve_nvd_id, position, operator, negate)
+			VALUES ($1, $2, NULLIF($3, ''), $4)
+			RETURNING id
+		`, cveNvdID, i, config.Operator, config.Negate).Scan(&configID)
+		if err != nil {
+			return err
+		}
+
+		for j, node := range config.Nodes {
+			var nodeID int
+			err := tx.QueryRow(ctx, `
+				INSERT INTO cve_nvd_cpe_node (config_id, position, operator, negate)
+				VALUES ($1, $2, NULLIF($3, ''), $4)
+				RETURNING id
+			`, configID, j, node.Operator, node.Negate).Scan(&nodeID)
+			if err != nil {
+				return err
+			}
+
+			for _, match := range node.CPEMatch {
+				name := parseCPE(match.Criteria)
+				_, err := tx.Exec(ctx, `
+					INSERT INTO cve_nvd_cpe_match (node_id, vulnerable, criteria, match_criteria_id, part, vendor, product, version,
+						version_start_including, version_start_excluding, version_end_including, version_end_excluding)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''))
+				`, nodeID, match.Vulnerable, match.Criteria, match.MatchCriteriaID, name.Part, name.Vendor, name.Product, name.Version,
+					match.VersionStartIncluding, match.VersionStartExcluding, match.VersionEndIncluding, match.VersionEndExcluding)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestParseCPE(t *testing.T) {
+	tests := []struct {
+		in   string
+		want CPEName
+	}{
+		{"cpe:2.3:a:apache:log4j:2.14.1:*:*:*:*:*:*:*", CPEName{"a", "apache", "log4j", "2.14.1"}},
+		{"cpe:2.3:o:microsoft:windows_10:-:*:*:*:*:*:*:*", CPEName{"o", "microsoft", "windows_10", "-"}},
+		{"cpe:2.3:a:php:php:5.3.0\\:rc1:*:*:*:*:*:*:*", CPEName{"a", "php", "php", "5.3.0:rc1"}},
+		{"cpe:2.3:h:cisco:asa_5505:*", CPEName{"h", "cisco", "asa_5505", "*"}},
+		{"cpe:2.3:a:vendor", CPEName{}},
+		{"cpe:/a:apache:log4j:2.14.1", CPEName{}},
+		{"", CPEName{}},
+	}
+	for _, tt := range tests {
+		if got := parseCPE(tt.in); got != tt.want {
+			t.Errorf("parseCPE(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
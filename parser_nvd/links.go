@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Функция для создания таблицы связей CVE из NVD с уязвимостями БДУ и переноса связей
+// из устаревшего столбца cve_nvd.vulnerability_id
+func createCveNvdVulnerabilityTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cve_nvd_vulnerability (
+			cve_nvd_id INTEGER NOT NULL,
+			vulnerability_id INTEGER NOT NULL,
+			PRIMARY KEY(cve_nvd_id, vulnerability_id),
+			FOREIGN KEY(cve_nvd_id) REFERENCES cve_nvd(id) ON DELETE CASCADE,
+			FOREIGN KEY(vulnerability_id) REFERENCES vulnerability(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS cve_nvd_vulnerability_vulnerability_id_idx ON cve_nvd_vulnerability (vulnerability_id);`,
+		`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'cve_nvd' AND column_name = 'vulnerability_id') THEN
+				INSERT INTO cve_nvd_vulnerability (cve_nvd_id, vulnerability_id)
+				SELECT id, vulnerability_id
+				FROM cve_nvd
+				WHERE vulnerability_id IS NOT NULL
+				ON CONFLICT DO NOTHING;
+				ALTER TABLE cve_nvd DROP COLUMN vulnerability_id;
+			END IF;
+		END $$;`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для пересчета связей CVE с уязвимостями по таблице cve_identifier.
+// Одна CVE связывается со всеми уязвимостями БДУ, в которых она упоминается;
+// связи с CVE, исчезнувшими из уязвимости, удаляются.
+func linkCVEsToVulnerabilities(ctx context.Context, dbpool *pgxpool.Pool, logger *log.Logger) error {
+	var added, removed int64
+	err := inTx(ctx, dbpool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO cve_nvd_vulnerability (cve_nvd_id, vulnerability_id)
+			SELECT DISTINCT cve_nvd.id, cve_identifier.vulnerability_id
+			FROM cve_identifier
+			JOIN cve_nvd ON cve_nvd.cve_id = cve_identifier.link
+			ON CONFLICT DO NOTHING
+		`)
+		if err != nil {
+			return err
+		}
+		added = tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `
+			DELETE FROM cve_nvd_vulnerability
+			USING cve_nvd
+			WHERE cve_nvd.id = cve_nvd_vulnerability.cve_nvd_id
+			AND NOT EXISTS (
+				SELECT 1 FROM cve_identifier
+				WHERE cve_identifier.vulnerability_id = cve_nvd_vulnerability.vulnerability_id
+				AND cve_nvd.cve_id = cve_identifier.link
+			)
+		`)
+		if err != nil {
+			return err
+		}
+		removed = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logger.Printf("Связи CVE с уязвимостями: добавлено %d, удалено %d\n", added, removed)
+	return nil
+}
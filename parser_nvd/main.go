@@ -2,20 +2,42 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
-	"net/http"
 	"os"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"vkr_common/cveid"
+
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 )
 
+const (
+	// Количество одновременно загружаемых CVE
+	nvdWorkers = 10
+	// Время работы с NVD API по умолчанию, меньше 30-минутного таймаута в cron_start_parsers.sh
+	nvdDefaultRunTimeout = 25 * time.Minute
+)
+
+// Функция для получения ограничения времени работы с NVD API из переменной NVD_RUN_TIMEOUT
+func nvdRunTimeout(logger *log.Logger) time.Duration {
+	value := os.Getenv("NVD_RUN_TIMEOUT")
+	if value == "" {
+		return nvdDefaultRunTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logger.Printf("Некорректное значение NVD_RUN_TIMEOUT %q, используется %s\n", value, nvdDefaultRunTimeout)
+		return nvdDefaultRunTimeout
+	}
+	return timeout
+}
+
 func main() {
 	// Загрузка переменных окружения из .env файла, который находится в поддиректории
 	err := godotenv.Load()
@@ -67,222 +89,382 @@ func main() {
 		logger.Fatalf("Ошибка создания таблицы cve_nvd: %v\n", err)
 	}
 
-	// Получить последние уязвимости
-	vulnerabilities, err := fetchLatestVulnerabilities(ctx, dbpool, logger)
+	err = createCveNvdStatusColumns(ctx, dbpool)
 	if err != nil {
-		logger.Fatalf("Ошибка при получении уязвимостей: %v\n", err)
+		logger.Fatalf("Ошибка добавления статуса CVE в таблицу cve_nvd: %v\n", err)
 	}
 
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10) // Ограничиваем количество параллельных запросов
+	err = createCveNvdVulnerabilityTable(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы cve_nvd_vulnerability: %v\n", err)
+	}
+
+	err = createCveNvdMetricTable(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы cve_nvd_metric: %v\n", err)
+	}
+
+	err = createCveNvdReferenceTable(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы cve_nvd_reference: %v\n", err)
+	}
+
+	err = createCveNvdCpeTables(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблиц конфигураций CPE: %v\n", err)
+	}
 
-	// Обработка каждой уязвимости
-	for _, vul := range vulnerabilities {
-		logger.Printf("Обработка уязвимости ID: %d, Identifier: %s\n", vul.ID, vul.Identifier)
+	err = createSyncStateTable(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы nvd_sync_state: %v\n", err)
+	}
 
-		// Получение идентификаторов CVE для уязвимости
-		cves, err := fetchCVEIdentifiers(ctx, dbpool, vul.ID, logger)
+	err = createBulkFileTable(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка создания таблицы nvd_bulk_file: %v\n", err)
+	}
+
+	// Клиент NVD API, ключ API задается в переменной NVD_API_KEY
+	client := NewNVDClient(os.Getenv("NVD_API_KEY"), logger)
+
+	// Инкрементальный режим: CVE из cve_nvd обновляются по дате изменения в NVD,
+	// по одной запрашиваются только CVE, которых еще нет в cve_nvd. NVD_SYNC_MODE=full принудительно
+	// обновляет все CVE, NVD_SYNC_MODE=bulk выполняет первичную загрузку из файлов выгрузки NVD
+	// по пути NVD_BULK_PATH.
+	syncStart := time.Now().UTC()
+	syncMode := os.Getenv("NVD_SYNC_MODE")
+
+	// Загрузка из NVD прекращается по истечении NVD_RUN_TIMEOUT, чтобы парсер успел сохранить
+	// связи и завершиться до принудительной остановки в cron_start_parsers.sh
+	fetchCtx, cancel := context.WithTimeout(ctx, nvdRunTimeout(logger))
+	defer cancel()
+
+	lastSync, incremental, err := loadLastSync(ctx, dbpool, nvdSyncName)
+	if err != nil {
+		logger.Fatalf("Ошибка при получении времени последней синхронизации: %v\n", err)
+	}
+	var failures int64
+	var bulkPending bool
+	switch syncMode {
+	case "full":
+		incremental = false
+	case "bulk":
+		// Первичная загрузка из файлов выгрузки NVD выполняется, пока не было ни одной синхронизации,
+		// и может занять несколько запусков. Только после загрузки всех файлов без ошибок время
+		// выгрузки сохраняется как время синхронизации: недостающие CVE запрашиваются через API,
+		// а изменения после даты выгрузки получаются инкрементально.
+		if incremental {
+			logger.Println("Синхронизация уже выполнялась, загрузка из выгрузки NVD пропущена")
+			break
+		}
+		bulk, err := importBulkFeeds(ctx, fetchCtx, dbpool, os.Getenv("NVD_BULK_PATH"), logger)
 		if err != nil {
-			logger.Printf("Ошибка при получении идентификаторов CVE для уязвимости ID %d: %v\n", vul.ID, err)
-			continue
+			logger.Fatalf("Ошибка при загрузке выгрузки NVD: %v\n", err)
+		}
+		if !bulk.Complete || bulk.FeedTime.IsZero() {
+			bulkPending = true
+			break
+		}
+		if err := saveLastSync(ctx, dbpool, nvdSyncName, bulk.FeedTime); err != nil {
+			logger.Fatalf("Ошибка при сохранении времени синхронизации: %v\n", err)
+		}
+		lastSync, incremental = bulk.FeedTime, true
+		logger.Println("Загрузка из выгрузки NVD завершена")
+	default:
+		// Первый запуск сразу сохраняет время, с которого следующие запуски получают изменения:
+		// первичная загрузка может занять несколько запусков, но каждый из них продолжает ее
+		// с CVE, которых еще нет в cve_nvd, а уже загруженные обновляются инкрементально
+		if !incremental {
+			lastSync, err = initialSyncTime(ctx, dbpool, syncStart)
+			if err != nil {
+				logger.Fatalf("Ошибка при определении времени начала синхронизации: %v\n", err)
+			}
+			if err := saveLastSync(ctx, dbpool, nvdSyncName, lastSync); err != nil {
+				logger.Fatalf("Ошибка при сохранении времени синхронизации: %v\n", err)
+			}
+			incremental = true
 		}
+	}
+	switch {
+	case bulkPending:
+		logger.Println("Загрузка из выгрузки NVD не завершена и продолжится при следующем запуске, запросы к API пропущены")
+	case incremental:
+		logger.Printf("Инкрементальная синхронизация, последняя синхронизация: %s\n", lastSync.Format(time.RFC3339))
+	default:
+		logger.Println("Полная синхронизация")
+	}
 
-		for _, cve := range cves {
-			wg.Add(1)
-			sem <- struct{}{}
-			go func(cve CVEIdentifier) {
-				defer wg.Done()
-				defer func() { <-sem }()
+	// Каждая CVE запрашивается не более одного раза за запуск, даже если она упоминается
+	// в нескольких уязвимостях: связи с уязвимостями восстанавливаются после загрузки
+	cveIDs, err := fetchDistinctCVEIDs(ctx, dbpool)
+	if err != nil {
+		logger.Fatalf("Ошибка при получении идентификаторов CVE: %v\n", err)
+	}
+	logger.Printf("Уникальных CVE в уязвимостях: %d\n", len(cveIDs))
 
-				cveLink := formatCVELink(cve.Link)
+	// Уже сохраненные CVE по одной не запрашиваются, кроме режима full
+	known := map[string]time.Time{}
+	if syncMode != "full" {
+		known, err = fetchKnownCVEs(ctx, dbpool)
+		if err != nil {
+			logger.Fatalf("Ошибка при получении сохраненных CVE: %v\n", err)
+		}
+		logger.Printf("CVE уже сохранены: %d\n", len(known))
+	}
 
-				// Проверка, существует ли запись в таблице cve_nvd
-				exists, err := cveExists(ctx, dbpool, cveLink, logger)
-				if err != nil {
-					logger.Printf("Ошибка при проверке существования CVE %s: %v\n", cveLink, err)
-					return
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+
+	// Ограничиваем количество параллельных запросов
+	for i := 0; i < nvdWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cveID := range jobs {
+				if !fetchAndSaveCVE(ctx, fetchCtx, client, dbpool, cveID, logger) {
+					atomic.AddInt64(&failures, 1)
 				}
+			}
+		}()
+	}
 
-				if exists {
-					logger.Printf("CVE %s уже существует в базе данных. Пропускаем.\n", cveLink)
-					return
-				}
+	// Пока загрузка из выгрузки не завершена, CVE по одной не запрашиваются
+	if bulkPending {
+		cveIDs = nil
+	}
+queue:
+	for _, cveID := range cveIDs {
+		if _, ok := known[cveID]; ok {
+			continue
+		}
+		select {
+		case jobs <- cveID:
+		case <-fetchCtx.Done():
+			break queue
+		}
+	}
+	close(jobs)
 
-				description, hyperlinks, err := fetchCVEDetails(cveLink, logger)
-				if err != nil {
-					logger.Printf("Ошибка при получении данных CVE для %s: %v\n", cveLink, err)
-					return
-				}
+	wg.Wait()
 
-				logger.Printf("CVE: %s, Описание: %s\n", cveLink, description)
-				err = saveCVEDetails(ctx, dbpool, cveLink, description, hyperlinks, vul.ID, logger)
-				if err != nil {
-					logger.Printf("Ошибка при сохранении данных CVE для %s: %v\n", cveLink, err)
-				}
-			}(cve)
+	if incremental && !bulkPending && fetchCtx.Err() == nil {
+		err = syncModifiedCVEs(fetchCtx, client, dbpool, known, lastSync, syncStart, logger)
+		if err != nil {
+			logger.Printf("Ошибка при получении измененных CVE: %v\n", err)
+			atomic.AddInt64(&failures, 1)
 		}
 	}
 
-	wg.Wait()
-	logger.Println("Скрапинг и сохранение данных CVE успешно завершены!")
+	// Истечение NVD_RUN_TIMEOUT ошибкой не считается: загруженные CVE и обработанные части
+	// интервала сохранены, следующий запуск продолжит с того же места
+	if fetchCtx.Err() != nil {
+		logger.Println("Время работы парсера истекло, синхронизация выполнена частично")
+	}
+
+	// Каждая CVE связывается со всеми уязвимостями БДУ, в которых она упоминается
+	err = linkCVEsToVulnerabilities(ctx, dbpool, logger)
+	if err != nil {
+		logger.Printf("Ошибка при связывании CVE с уязвимостями: %v\n", err)
+		atomic.AddInt64(&failures, 1)
+	}
+
+	// В инкрементальном режиме время синхронизации сохраняется после каждой части интервала
+	// в syncModifiedCVEs. Полное обновление сохраняет его, только если все CVE обработаны без ошибок,
+	// иначе следующий запуск повторит тот же интервал. Незавершенная загрузка из выгрузки
+	// время синхронизации не сохраняет.
+	if !incremental && !bulkPending && failures == 0 && fetchCtx.Err() == nil {
+		err = saveLastSync(ctx, dbpool, nvdSyncName, syncStart)
+		if err != nil {
+			logger.Printf("Ошибка при сохранении времени синхронизации: %v\n", err)
+		}
+	} else if failures > 0 {
+		logger.Printf("Ошибок при синхронизации: %d\n", failures)
+	}
+
+	logger.Println("Получение и сохранение данных CVE успешно завершены!")
 }
 
 func createCveNvdTable(ctx context.Context, dbpool *pgxpool.Pool, logger *log.Logger) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS cve_nvd (
 			id SERIAL PRIMARY KEY,
-			cve_link TEXT UNIQUE,
+			cve_id TEXT NOT NULL UNIQUE,
 			description TEXT,
-			last_fetched TIMESTAMP,
-			vulnerability_id INTEGER,
-			hyperlinks TEXT,
-			FOREIGN KEY(vulnerability_id) REFERENCES vulnerability(id)
+			last_fetched TIMESTAMP
 		);
 	`
 	_, err := dbpool.Exec(ctx, query)
 	if err != nil {
 		logger.Printf("Ошибка при создании таблицы cve_nvd: %v\n", err)
+		return err
 	}
-	return err
-}
-
-type Vulnerability struct {
-	ID         int
-	Identifier string
-}
 
-type CVEIdentifier struct {
-	ID              int
-	Type            string
-	Link            string
-	VulnerabilityID int
+	// Ранее записи cve_nvd идентифицировались ссылкой на NVD в столбце cve_link,
+	// теперь ключом служит идентификатор CVE, а ссылка строится при отображении
+	var hasLink bool
+	err = dbpool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'cve_nvd' AND column_name = 'cve_link')
+	`).Scan(&hasLink)
+	if err == nil && hasLink {
+		err = inTx(ctx, dbpool, func(tx pgx.Tx) error {
+			return migrateCveNvdLinks(ctx, tx)
+		})
+	}
+	if err != nil {
+		logger.Printf("Ошибка при переносе идентификаторов CVE в cve_nvd: %v\n", err)
+	}
+	return err
 }
 
-func fetchLatestVulnerabilities(ctx context.Context, dbpool *pgxpool.Pool, logger *log.Logger) ([]Vulnerability, error) {
-	query := `
-		SELECT id, identifier 
-		FROM vulnerability 
-		ORDER BY identifier DESC
-	`
+// Функция для заполнения cve_id по ссылке cve_link тем же разбором, что и для cve_identifier
+func migrateCveNvdLinks(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, `ALTER TABLE cve_nvd ADD COLUMN IF NOT EXISTS cve_id TEXT`); err != nil {
+		return err
+	}
 
-	rows, err := dbpool.Query(ctx, query)
+	rows, err := tx.Query(ctx, `SELECT id, COALESCE(cve_link, '') FROM cve_nvd`)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	var vulnerabilities []Vulnerability
+	cveIDs := make(map[int]string)
 	for rows.Next() {
-		var vul Vulnerability
-		if err := rows.Scan(&vul.ID, &vul.Identifier); err != nil {
-			return nil, err
+		var id int
+		var link string
+		if err := rows.Scan(&id, &link); err != nil {
+			rows.Close()
+			return err
+		}
+		if ids := cveid.Parse(link); len(ids) > 0 {
+			cveIDs[id] = ids[0]
 		}
-		vulnerabilities = append(vulnerabilities, vul)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	return vulnerabilities, nil
+	for id, cveID := range cveIDs {
+		if _, err := tx.Exec(ctx, `UPDATE cve_nvd SET cve_id = $1 WHERE id = $2`, cveID, id); err != nil {
+			return err
+		}
+	}
+
+	queries := []string{
+		`DELETE FROM cve_nvd WHERE cve_id IS NULL`,
+		`DELETE FROM cve_nvd a USING cve_nvd b WHERE a.cve_id = b.cve_id AND a.id > b.id`,
+		`ALTER TABLE cve_nvd ALTER COLUMN cve_id SET NOT NULL`,
+		`ALTER TABLE cve_nvd ADD CONSTRAINT cve_nvd_cve_id_key UNIQUE (cve_id)`,
+		`ALTER TABLE cve_nvd DROP COLUMN cve_link`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func fetchCVEIdentifiers(ctx context.Context, dbpool *pgxpool.Pool, vulnerabilityID int, logger *log.Logger) ([]CVEIdentifier, error) {
+// Функция для получения списка уникальных идентификаторов CVE из всех уязвимостей,
+// начиная с CVE последних уязвимостей
+func fetchDistinctCVEIDs(ctx context.Context, dbpool *pgxpool.Pool) ([]string, error) {
 	query := `
-		SELECT id, type, link, vulnerability_id 
-		FROM cve_identifier 
-		WHERE vulnerability_id = $1
+		SELECT cve_identifier.link
+		FROM cve_identifier
+		JOIN vulnerability ON vulnerability.id = cve_identifier.vulnerability_id
+		ORDER BY vulnerability.identifier DESC
 	`
 
-	rows, err := dbpool.Query(ctx, query, vulnerabilityID)
+	rows, err := dbpool.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var cveIdentifiers []CVEIdentifier
+	seen := make(map[string]bool)
+	var cveIDs []string
 	for rows.Next() {
-		var cve CVEIdentifier
-		if err := rows.Scan(&cve.ID, &cve.Type, &cve.Link, &cve.VulnerabilityID); err != nil {
+		var link string
+		if err := rows.Scan(&link); err != nil {
 			return nil, err
 		}
-		cveIdentifiers = append(cveIdentifiers, cve)
+		for _, cveID := range cveid.Parse(link) {
+			if seen[cveID] {
+				continue
+			}
+			seen[cveID] = true
+			cveIDs = append(cveIDs, cveID)
+		}
 	}
 
-	return cveIdentifiers, nil
+	return cveIDs, rows.Err()
 }
 
-func formatCVELink(link string) string {
-	if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
-		return "https://nvd.nist.gov/vuln/detail/" + link
+// Функция для загрузки одной CVE из NVD и ее сохранения, возвращает false при ошибке.
+// CVE, отсутствующая в NVD, и запрос, прерванный по истечении времени работы, ошибкой не считаются.
+func fetchAndSaveCVE(ctx, fetchCtx context.Context, client *NVDClient, dbpool *pgxpool.Pool, cveID string, logger *log.Logger) bool {
+	cveItem, err := client.FetchCVE(fetchCtx, cveID)
+	if errors.Is(err, errCVENotFound) {
+		logger.Printf("CVE %s отсутствует в NVD\n", cveID)
+		return true
 	}
-	return link
-}
-
-func fetchCVEDetails(cveLink string, logger *log.Logger) (string, []string, error) {
-	var description string
-	var hyperlinks []string
-
-	const maxRetries = 5
-	for i := 0; i < maxRetries; i++ {
-		// Выполнение HTTP запроса
-		res, err := http.Get(cveLink)
-		if err != nil {
-			logger.Printf("Попытка %d: ошибка при получении URL %s: %v\n", i+1, cveLink, err)
-			time.Sleep(time.Duration(rand.Intn(2000-1)+1) * time.Millisecond)
-			continue
-		}
-
-		if res.StatusCode != 200 {
-			logger.Printf("Попытка %d: ошибка: получен ненормативный код ответа %d для URL %s\n", i+1, res.StatusCode, cveLink)
-			res.Body.Close()
-			time.Sleep(time.Duration(rand.Intn(2000-1)+1) * time.Millisecond)
-			continue
-		}
-
-		// Парсинг HTML ответа
-		doc, err := goquery.NewDocumentFromReader(res.Body)
-		res.Body.Close()
-		if err != nil {
-			logger.Printf("Попытка %d: ошибка при парсинге HTML для URL %s: %v\n", i+1, cveLink, err)
-			time.Sleep(time.Duration(rand.Intn(2000-1)+1) * time.Millisecond)
-			continue
-		}
-
-		// Найти описание CVE
-		description = doc.Find("p[data-testid='vuln-description']").Text()
-		description = strings.TrimSpace(description)
-
-		// Найти гиперссылки
-		doc.Find("table[data-testid='vuln-hyperlinks-table'] tbody tr").Each(func(i int, s *goquery.Selection) {
-			hyperlink := s.Find("td[data-testid^='vuln-hyperlinks-link-'] a").AttrOr("href", "")
-			hyperlinks = append(hyperlinks, hyperlink)
-		})
-
-		return description, hyperlinks, nil
+	if err != nil && fetchCtx.Err() != nil {
+		// Запрос прерван по истечении времени работы, CVE будет загружена при следующем запуске
+		return true
 	}
-
-	return "", nil, fmt.Errorf("превышено максимальное количество попыток для URL: %s", cveLink)
+	if err != nil {
+		logger.Printf("Ошибка при получении данных CVE для %s: %v\n", cveID, err)
+		return false
+	}
+	logger.Printf("CVE: %s, Статус: %s, Описание: %s\n", cveID, cveItem.VulnStatus, cveItem.Description())
+	return saveCVEDetails(ctx, dbpool, cveID, cveItem, logger) == nil
 }
 
-func saveCVEDetails(ctx context.Context, dbpool *pgxpool.Pool, cveLink, description string, hyperlinks []string, vulnerabilityID int, logger *log.Logger) error {
-	hyperlinksStr := strings.Join(hyperlinks, "; ")
-
+func saveCVEDetails(ctx context.Context, dbpool *pgxpool.Pool, cveID string, cveItem *CVEItem, logger *log.Logger) error {
 	query := `
-		INSERT INTO cve_nvd (cve_link, description, last_fetched, vulnerability_id, hyperlinks)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (cve_link) DO UPDATE
-		SET description = EXCLUDED.description, last_fetched = EXCLUDED.last_fetched, hyperlinks = EXCLUDED.hyperlinks;
+		INSERT INTO cve_nvd (cve_id, description, last_fetched, vuln_status, published, last_modified, rejection_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (cve_id) DO UPDATE
+		SET description = EXCLUDED.description, last_fetched = EXCLUDED.last_fetched,
+			vuln_status = EXCLUDED.vuln_status, published = EXCLUDED.published,
+			last_modified = EXCLUDED.last_modified, rejection_reason = EXCLUDED.rejection_reason
+		RETURNING id;
 	`
-	_, err := dbpool.Exec(ctx, query, cveLink, description, time.Now(), vulnerabilityID, hyperlinksStr)
+	err := inTx(ctx, dbpool, func(tx pgx.Tx) error {
+		var cveNvdID int
+		err := tx.QueryRow(ctx, query, cveID, cveItem.Description(), time.Now(), cveItem.VulnStatus,
+			nvdTimeOrNil(cveItem.Published), nvdTimeOrNil(cveItem.LastModified), cveItem.RejectionReason()).Scan(&cveNvdID)
+		if err != nil {
+			return err
+		}
+		return saveCVEChildren(ctx, tx, cveNvdID, cveItem)
+	})
 	if err != nil {
-		logger.Printf("Ошибка при сохранении данных CVE для %s: %v\n", cveLink, err)
+		logger.Printf("Ошибка при сохранении данных CVE для %s: %v\n", cveID, err)
 	}
 	return err
 }
 
-func cveExists(ctx context.Context, dbpool *pgxpool.Pool, cveLink string, logger *log.Logger) (bool, error) {
-	var exists bool
-	query := `SELECT EXISTS (SELECT 1 FROM cve_nvd WHERE cve_link = $1)`
-	err := dbpool.QueryRow(ctx, query, cveLink).Scan(&exists)
+// Функция для сохранения связанных с записью cve_nvd данных: оценок CVSS, ссылок и конфигураций CPE
+func saveCVEChildren(ctx context.Context, tx pgx.Tx, cveNvdID int, cveItem *CVEItem) error {
+	if err := saveCVEMetrics(ctx, tx, cveNvdID, cveItem.Metrics); err != nil {
+		return err
+	}
+	if err := saveCVEReferences(ctx, tx, cveNvdID, cveItem.References); err != nil {
+		return err
+	}
+	return saveCVEConfigurations(ctx, tx, cveNvdID, cveItem.Configurations)
+}
+
+// Функция для выполнения fn в транзакции
+func inTx(ctx context.Context, dbpool *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	tx, err := dbpool.Begin(ctx)
 	if err != nil {
-		logger.Printf("Ошибка при проверке существования CVE %s: %v\n", cveLink, err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
 	}
-	return exists, err
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CVEMetrics - оценки CVSS всех версий из NVD, от основного (Primary) и дополнительных (Secondary) источников
+type CVEMetrics struct {
+	V40 []CVSSMetric `json:"cvssMetricV40"`
+	V31 []CVSSMetric `json:"cvssMetricV31"`
+	V30 []CVSSMetric `json:"cvssMetricV30"`
+	V2  []CVSSMetric `json:"cvssMetricV2"`
+}
+
+type CVSSMetric struct {
+	Source   string   `json:"source"`
+	Type     string   `json:"type"`
+	CVSSData CVSSData `json:"cvssData"`
+	// В CVSS v2 уровень опасности указывается вне cvssData
+	BaseSeverity        string   `json:"baseSeverity"`
+	ExploitabilityScore *float64 `json:"exploitabilityScore"`
+	ImpactScore         *float64 `json:"impactScore"`
+}
+
+type CVSSData struct {
+	Version      string  `json:"version"`
+	VectorString string  `json:"vectorString"`
+	BaseScore    float64 `json:"baseScore"`
+	BaseSeverity string  `json:"baseSeverity"`
+}
+
+// All возвращает оценки всех версий CVSS одним списком
+func (m CVEMetrics) All() []CVSSMetric {
+	var all []CVSSMetric
+	for _, metrics := range [][]CVSSMetric{m.V40, m.V31, m.V30, m.V2} {
+		all = append(all, metrics...)
+	}
+	return all
+}
+
+// Severity возвращает уровень опасности независимо от версии CVSS
+func (m CVSSMetric) Severity() string {
+	if m.CVSSData.BaseSeverity != "" {
+		return m.CVSSData.BaseSeverity
+	}
+	return m.BaseSeverity
+}
+
+// Функция для создания таблицы оценок CVSS из NVD
+func createCveNvdMetricTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	_, err := dbpool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS cve_nvd_metric (
+			id SERIAL PRIMARY KEY,
+			cve_nvd_id INTEGER NOT NULL,
+			cvss_version TEXT NOT NULL,
+			source TEXT NOT NULL,
+			type TEXT NOT NULL,
+			vector_string TEXT,
+			base_score NUMERIC(3, 1),
+			base_severity TEXT,
+			exploitability_score NUMERIC(3, 1),
+			impact_score NUMERIC(3, 1),
+			FOREIGN KEY(cve_nvd_id) REFERENCES cve_nvd(id) ON DELETE CASCADE,
+			UNIQUE(cve_nvd_id, cvss_version, source, type)
+		);
+	`)
+	return err
+}
+
+// Функция для замены оценок CVSS записи cve_nvd
+func saveCVEMetrics(ctx context.Context, tx pgx.Tx, cveNvdID int, metrics CVEMetrics) error {
+	_, err := tx.Exec(ctx, `DELETE FROM cve_nvd_metric WHERE cve_nvd_id = $1`, cveNvdID)
+	if err != nil {
+		return err
+	}
+
+	for _, metric := range metrics.All() {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO cve_nvd_metric (cve_nvd_id, cvss_version, source, type, vector_string, base_score, base_severity, exploitability_score, impact_score)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (cve_nvd_id, cvss_version, source, type) DO NOTHING
+		`, cveNvdID, metric.CVSSData.Version, metric.Source, metric.Type, metric.CVSSData.VectorString,
+			metric.CVSSData.BaseScore, metric.Severity(), metric.ExploitabilityScore, metric.ImpactScore)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
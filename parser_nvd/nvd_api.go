@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// Адрес NVD CVE API 2.0
+	nvdAPIURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+	// Максимальный размер страницы, допускаемый API
+	nvdMaxResultsPerPage = 2000
+	// Документированные ограничения NVD: 5 запросов за 30 секунд без ключа и 50 с ключом
+	nvdRateWindow      = 30 * time.Second
+	nvdRequestsNoKey   = 5
+	nvdRequestsWithKey = 50
+	nvdMaxRetries      = 5
+	// Время ожидания ответа на один запрос, включая чтение тела
+	nvdRequestTimeout = time.Minute
+)
+
+// errCVENotFound - CVE отсутствует в NVD (например, зарезервирована, но не опубликована)
+var errCVENotFound = errors.New("CVE не найдена в NVD")
+
+// NVDClient - клиент NVD CVE API 2.0
+type NVDClient struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	logger     *log.Logger
+
+	// Общий для всех горутин ограничитель частоты запросов
+	limiter *rateLimiter
+}
+
+// NewNVDClient создает клиент NVD API. Ключ API необязателен, но повышает допустимую частоту запросов.
+func NewNVDClient(apiKey string, logger *log.Logger) *NVDClient {
+	requests := nvdRequestsNoKey
+	if apiKey != "" {
+		requests = nvdRequestsWithKey
+	}
+	return &NVDClient{
+		httpClient: &http.Client{},
+		baseURL:    nvdAPIURL,
+		apiKey:     apiKey,
+		logger:     logger,
+		limiter:    newRateLimiter(requests, nvdRateWindow),
+	}
+}
+
+// cveResponse - страница ответа NVD CVE API 2.0
+type cveResponse struct {
+	ResultsPerPage  int `json:"resultsPerPage"`
+	StartIndex      int `json:"startIndex"`
+	TotalResults    int `json:"totalResults"`
+	Vulnerabilities []struct {
+		CVE CVEItem `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+// CVEItem - запись CVE из NVD
+type CVEItem struct {
+	ID             string             `json:"id"`
+	Published      string             `json:"published"`
+	LastModified   string             `json:"lastModified"`
+	VulnStatus     string             `json:"vulnStatus"`
+	Descriptions   []LangString       `json:"descriptions"`
+	Metrics        CVEMetrics         `json:"metrics"`
+	Configurations []CVEConfiguration `json:"configurations"`
+	References     []CVEReference     `json:"references"`
+}
+
+type LangString struct {
+	Lang  string `json:"lang"`
+	Value string `json:"value"`
+}
+
+type CVEReference struct {
+	URL    string   `json:"url"`
+	Source string   `json:"source"`
+	Tags   []string `json:"tags"`
+}
+
+// Description возвращает англоязычное описание CVE
+func (c CVEItem) Description() string {
+	for _, d := range c.Descriptions {
+		if d.Lang == "en" {
+			return d.Value
+		}
+	}
+	if len(c.Descriptions) > 0 {
+		return c.Descriptions[0].Value
+	}
+	return ""
+}
+
+// FetchCVE получает одну запись CVE по идентификатору
+func (c *NVDClient) FetchCVE(ctx context.Context, cveID string) (*CVEItem, error) {
+	params := url.Values{}
+	params.Set("cveId", cveID)
+
+	page, err := c.fetchPage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Vulnerabilities) == 0 {
+		return nil, fmt.Errorf("%w: %s", errCVENotFound, cveID)
+	}
+	return &page.Vulnerabilities[0].CVE, nil
+}
+
+// FetchCVEs постранично получает записи CVE по параметрам запроса и передает каждую в fn
+func (c *NVDClient) FetchCVEs(ctx context.Context, params url.Values, fn func(CVEItem) error) error {
+	startIndex := 0
+	for {
+		pageParams := url.Values{}
+		for k, v := range params {
+			pageParams[k] = v
+		}
+		pageParams.Set("resultsPerPage", strconv.Itoa(nvdMaxResultsPerPage))
+		pageParams.Set("startIndex", strconv.Itoa(startIndex))
+
+		page, err := c.fetchPage(ctx, pageParams)
+		if err != nil {
+			return err
+		}
+		for _, v := range page.Vulnerabilities {
+			if err := fn(v.CVE); err != nil {
+				return err
+			}
+		}
+
+		startIndex += len(page.Vulnerabilities)
+		if len(page.Vulnerabilities) == 0 || startIndex >= page.TotalResults {
+			return nil
+		}
+	}
+}
+
+// errRetryable - ошибка запроса, после которой имеет смысл повторная попытка
+type errRetryable struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *errRetryable) Error() string {
+	return e.err.Error()
+}
+
+// fetchPage выполняет один запрос к API с повторными попытками.
+// Повторяются только сетевые ошибки, ответы 429 и 5xx; при 429/503 снижается частота запросов.
+func (c *NVDClient) fetchPage(ctx context.Context, params url.Values) (*cveResponse, error) {
+	requestURL := c.baseURL + "?" + params.Encode()
+
+	for i := 0; i < nvdMaxRetries; i++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		page, err := c.doRequest(ctx, requestURL)
+		if err == nil {
+			c.limiter.Recover()
+			return page, nil
+		}
+
+		var retryable *errRetryable
+		if !errors.As(err, &retryable) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := backoff(i)
+		if retryable.retryAfter > delay {
+			delay = retryable.retryAfter
+		}
+		c.logger.Printf("Попытка %d: %v, повтор через %s\n", i+1, err, delay.Round(time.Second))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, fmt.Errorf("превышено максимальное количество попыток для %s", requestURL)
+}
+
+// doRequest выполняет один HTTP-запрос с ограничением времени ожидания
+func (c *NVDClient) doRequest(ctx context.Context, requestURL string) (*cveResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, nvdRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("apiKey", c.apiKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &errRetryable{err: fmt.Errorf("ошибка при запросе %s: %w", requestURL, err)}
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable:
+		retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+		c.limiter.Throttle(retryAfter)
+		return nil, &errRetryable{
+			err:        fmt.Errorf("NVD ограничивает частоту запросов, код ответа %d для %s", res.StatusCode, requestURL),
+			retryAfter: retryAfter,
+		}
+	case res.StatusCode >= http.StatusInternalServerError:
+		return nil, &errRetryable{err: fmt.Errorf("получен ненормативный код ответа %d для %s", res.StatusCode, requestURL)}
+	default:
+		return nil, fmt.Errorf("получен ненормативный код ответа %d для %s", res.StatusCode, requestURL)
+	}
+
+	var page cveResponse
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		return nil, &errRetryable{err: fmt.Errorf("ошибка при разборе JSON для %s: %w", requestURL, err)}
+	}
+	return &page, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Доля лимита NVD, которую можно израсходовать сразу, остальные запросы равномерно распределяются по окну
+	nvdBurstDivisor = 5
+	// Во сколько раз допускается снижать частоту запросов при ответах 429/503
+	nvdMaxSlowdown = 8
+	// Параметры экспоненциальной задержки между повторными попытками
+	nvdBackoffBase = 2 * time.Second
+	nvdBackoffMax  = 2 * time.Minute
+)
+
+// rateLimiter - общий для всех горутин ограничитель частоты запросов по алгоритму token bucket.
+// При ответах 429/503 частота запросов снижается и все запросы приостанавливаются
+// на время Retry-After, после успешных ответов частота постепенно восстанавливается.
+type rateLimiter struct {
+	mu          sync.Mutex
+	capacity    float64
+	tokens      float64
+	maxRate     float64 // токенов в секунду
+	rate        float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// Функция для создания ограничителя, допускающего не более requests запросов за окно window
+func newRateLimiter(requests int, window time.Duration) *rateLimiter {
+	burst := requests / nvdBurstDivisor
+	if burst < 1 {
+		burst = 1
+	}
+	// Полная корзина и пополнение за окно в сумме не превышают лимит
+	rate := float64(requests-burst) / window.Seconds()
+	return &rateLimiter{
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		maxRate:  rate,
+		rate:     rate,
+		last:     time.Now(),
+	}
+}
+
+// Wait ожидает, пока не будет доступен токен для очередного запроса
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve забирает токен и возвращает 0 или время, через которое стоит повторить попытку
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Throttle вызывается при ответе 429/503: частота запросов снижается вдвое,
+// а при заданном Retry-After все запросы приостанавливаются на это время
+func (l *rateLimiter) Throttle(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate /= 2
+	if floor := l.maxRate / nvdMaxSlowdown; l.rate < floor {
+		l.rate = floor
+	}
+	l.tokens = 0
+	if until := time.Now().Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Recover вызывается после успешного ответа и постепенно возвращает исходную частоту запросов
+func (l *rateLimiter) Recover() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate *= 1.1
+	if l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+}
+
+// Функция для вычисления задержки перед повторной попыткой: экспоненциальный рост с джиттером
+func backoff(attempt int) time.Duration {
+	d := nvdBackoffBase << attempt
+	if d <= 0 || d > nvdBackoffMax {
+		d = nvdBackoffMax
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Функция для разбора заголовка Retry-After, заданного в секундах или в виде даты HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
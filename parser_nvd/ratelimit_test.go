@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"30", 30 * time.Second, 30 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), 100 * time.Second, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want [%v, %v]", tt.in, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 70; attempt++ {
+		full := nvdBackoffBase << attempt
+		if full <= 0 || full > nvdBackoffMax {
+			full = nvdBackoffMax
+		}
+		for i := 0; i < 20; i++ {
+			if got := backoff(attempt); got < full/2 || got > full {
+				t.Fatalf("backoff(%d) = %v, want [%v, %v]", attempt, got, full/2, full)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Функция для создания таблицы ссылок NVD и переноса ссылок из устаревшего столбца cve_nvd.hyperlinks
+func createCveNvdReferenceTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cve_nvd_reference (
+			id SERIAL PRIMARY KEY,
+			cve_nvd_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			source TEXT,
+			tags TEXT[] NOT NULL DEFAULT '{}',
+			FOREIGN KEY(cve_nvd_id) REFERENCES cve_nvd(id) ON DELETE CASCADE,
+			UNIQUE(cve_nvd_id, url)
+		);`,
+		`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'cve_nvd' AND column_name = 'hyperlinks') THEN
+				INSERT INTO cve_nvd_reference (cve_nvd_id, url)
+				SELECT DISTINCT id, trim(link)
+				FROM cve_nvd, unnest(string_to_array(hyperlinks, ';')) AS link
+				WHERE trim(link) <> ''
+				ON CONFLICT (cve_nvd_id, url) DO NOTHING;
+				ALTER TABLE cve_nvd DROP COLUMN hyperlinks;
+			END IF;
+		END $$;`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для синхронизации ссылок записи cve_nvd: новые добавляются, существующие обновляются,
+// исчезнувшие из NVD удаляются
+func saveCVEReferences(ctx context.Context, tx pgx.Tx, cveNvdID int, references []CVEReference) error {
+	urls := make([]string, 0, len(references))
+	for _, ref := range references {
+		tags := ref.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO cve_nvd_reference (cve_nvd_id, url, source, tags)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (cve_nvd_id, url) DO UPDATE
+			SET source = EXCLUDED.source, tags = EXCLUDED.tags
+		`, cveNvdID, ref.URL, ref.Source, tags)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, ref.URL)
+	}
+
+	_, err := tx.Exec(ctx, `DELETE FROM cve_nvd_reference WHERE cve_nvd_id = $1 AND NOT (url = ANY($2))`, cveNvdID, urls)
+	return err
+}
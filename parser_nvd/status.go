@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Статус CVE, отклоненной NVD или присвоившим ее CNA
+const nvdStatusRejected = "Rejected"
+
+// Функция для добавления в cve_nvd статуса анализа NVD, дат публикации и изменения,
+// причины отклонения, а также представления с уязвимостями БДУ, все CVE которых отклонены
+func createCveNvdStatusColumns(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`ALTER TABLE cve_nvd ADD COLUMN IF NOT EXISTS vuln_status TEXT;`,
+		`ALTER TABLE cve_nvd ADD COLUMN IF NOT EXISTS published TIMESTAMP;`,
+		`ALTER TABLE cve_nvd ADD COLUMN IF NOT EXISTS last_modified TIMESTAMP;`,
+		`ALTER TABLE cve_nvd ADD COLUMN IF NOT EXISTS rejection_reason TEXT;`,
+		`CREATE OR REPLACE VIEW vulnerability_cve_rejected AS
+		SELECT cve_identifier.vulnerability_id
+		FROM cve_identifier
+		LEFT JOIN cve_nvd ON cve_nvd.cve_id = cve_identifier.link
+		GROUP BY cve_identifier.vulnerability_id
+		HAVING bool_and(COALESCE(cve_nvd.vuln_status = '` + nvdStatusRejected + `', FALSE));`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RejectionReason возвращает причину отклонения CVE или пустую строку, если CVE не отклонена.
+// NVD публикует причину в описании: "Rejected reason: ..." или "** REJECT ** ...".
+func (c CVEItem) RejectionReason() string {
+	if c.VulnStatus != nvdStatusRejected {
+		return ""
+	}
+	reason := strings.TrimSpace(c.Description())
+	for _, prefix := range []string{"Rejected reason:", "** REJECT **"} {
+		if strings.HasPrefix(reason, prefix) {
+			reason = strings.TrimSpace(strings.TrimPrefix(reason, prefix))
+		}
+	}
+	return reason
+}
+
+// Функция для преобразования даты NVD для записи в базу: nil, если дата не задана или некорректна
+func nvdTimeOrNil(value string) *time.Time {
+	t, err := parseNVDTime(value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
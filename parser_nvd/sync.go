@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// Имя записи о синхронизации через NVD API в таблице nvd_sync_state
+	nvdSyncName = "nvd_api"
+	// NVD API допускает диапазон lastModStartDate/lastModEndDate не более 120 дней
+	nvdMaxModRange = 120 * 24 * time.Hour
+	// Формат дат NVD API
+	nvdDateLayout = "2006-01-02T15:04:05.000"
+	// Насколько далеко в прошлое может начинаться первая инкрементальная синхронизация
+	nvdInitialSyncLimit = nvdMaxModRange
+)
+
+// Функция для создания таблицы с временем последней успешной синхронизации
+func createSyncStateTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	_, err := dbpool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS nvd_sync_state (
+			name TEXT PRIMARY KEY,
+			last_sync TIMESTAMPTZ NOT NULL
+		);
+	`)
+	return err
+}
+
+// Функция для получения времени последней успешной синхронизации, ok=false если синхронизаций не было
+func loadLastSync(ctx context.Context, dbpool *pgxpool.Pool, name string) (time.Time, bool, error) {
+	var lastSync time.Time
+	err := dbpool.QueryRow(ctx, `SELECT last_sync FROM nvd_sync_state WHERE name = $1`, name).Scan(&lastSync)
+	if err == pgx.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return lastSync, true, nil
+}
+
+// Функция для сохранения времени успешной синхронизации
+func saveLastSync(ctx context.Context, dbpool *pgxpool.Pool, name string, syncTime time.Time) error {
+	_, err := dbpool.Exec(ctx, `
+		INSERT INTO nvd_sync_state (name, last_sync)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_sync = EXCLUDED.last_sync;
+	`, name, syncTime)
+	return err
+}
+
+// syncWindow - часть интервала синхронизации [Start, End), запрашиваемая у NVD API одним диапазоном
+type syncWindow struct {
+	Start, End time.Time
+}
+
+// Функция для разбиения интервала [from, to) на части не длиннее 120 дней, допускаемых NVD API
+func syncWindows(from, to time.Time) []syncWindow {
+	var windows []syncWindow
+	for start := from; start.Before(to); start = start.Add(nvdMaxModRange) {
+		end := start.Add(nvdMaxModRange)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, syncWindow{Start: start, End: end})
+	}
+	return windows
+}
+
+// Функция для обновления записей cve_nvd, измененных в NVD в интервале [from, to).
+// Интервал разбивается на части по 120 дней, обновляются только CVE из known, у которых изменилась
+// дата lastModified. Ошибка сохранения одной CVE записывается в журнал и не прерывает часть,
+// обработку прерывают только ошибки запросов к NVD. После каждой части без ошибок ее конец
+// сохраняется как время синхронизации, поэтому прерванная синхронизация продолжается со следующей
+// части, а часть с несохраненными CVE повторяется при следующем запуске. Истечение времени работы (ctx)
+// не считается ошибкой: синхронизация завершается частично на последней обработанной части.
+func syncModifiedCVEs(ctx context.Context, client *NVDClient, dbpool *pgxpool.Pool, known map[string]time.Time, from, to time.Time, logger *log.Logger) error {
+	// Ограничение времени относится к запросам к NVD, запись в базу не прерывается
+	dbCtx := context.WithoutCancel(ctx)
+	updated, unchanged, failed := 0, 0, 0
+	defer func() {
+		logger.Printf("Обновлено измененных CVE: %d, без изменений: %d, с ошибками: %d\n", updated, unchanged, failed)
+	}()
+	for _, window := range syncWindows(from, to) {
+		params := url.Values{}
+		params.Set("lastModStartDate", formatNVDDate(window.Start))
+		params.Set("lastModEndDate", formatNVDDate(window.End))
+		logger.Printf("Запрос CVE, измененных с %s по %s\n", window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339))
+
+		var windowFailed []string
+		err := client.FetchCVEs(ctx, params, func(cveItem CVEItem) error {
+			cveID := strings.ToUpper(cveItem.ID)
+			lastModified, ok := known[cveID]
+			if !ok {
+				return nil
+			}
+			if modified := nvdTimeOrNil(cveItem.LastModified); modified != nil && modified.Equal(lastModified) {
+				unchanged++
+				return nil
+			}
+			if err := updateCVEDetails(dbCtx, dbpool, cveID, &cveItem, logger); err != nil {
+				windowFailed = append(windowFailed, cveID)
+				failed++
+				return nil
+			}
+			updated++
+			return nil
+		})
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.Printf("Время работы истекло, изменения синхронизированы частично: по %s\n", window.Start.Format(time.RFC3339))
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(windowFailed) > 0 {
+			logger.Printf("Не удалось обновить CVE, измененные с %s по %s (%d): %s\n", window.Start.Format(time.RFC3339),
+				window.End.Format(time.RFC3339), len(windowFailed), strings.Join(windowFailed, ", "))
+		}
+
+		// Время синхронизации не переносится дальше первой части с ошибками
+		if failed == 0 {
+			if err := saveLastSync(dbCtx, dbpool, nvdSyncName, window.End); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("не удалось обновить CVE: %d", failed)
+	}
+	return nil
+}
+
+// Функция для форматирования даты для параметров lastModStartDate/lastModEndDate
+func formatNVDDate(t time.Time) string {
+	return t.UTC().Format(nvdDateLayout) + "+00:00"
+}
+
+// Функция для получения CVE, уже сохраненных в cve_nvd, с датой их изменения в NVD
+// (нулевое время, если дата не загружена)
+func fetchKnownCVEs(ctx context.Context, dbpool *pgxpool.Pool) (map[string]time.Time, error) {
+	rows, err := dbpool.Query(ctx, `SELECT cve_id, last_modified FROM cve_nvd`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]time.Time)
+	for rows.Next() {
+		var cveID string
+		var lastModified *time.Time
+		if err := rows.Scan(&cveID, &lastModified); err != nil {
+			return nil, err
+		}
+		known[cveID] = time.Time{}
+		if lastModified != nil {
+			known[cveID] = *lastModified
+		}
+	}
+	return known, rows.Err()
+}
+
+// Функция для получения времени, с которого начинается инкрементальная синхронизация при первом запуске
+func initialSyncTime(ctx context.Context, dbpool *pgxpool.Pool, now time.Time) (time.Time, error) {
+	var earliest *time.Time
+	err := dbpool.QueryRow(ctx, `SELECT min(last_fetched) FROM cve_nvd`).Scan(&earliest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return initialSyncStart(earliest, now), nil
+}
+
+// Функция для выбора начала первой инкрементальной синхронизации: самое раннее время загрузки
+// сохраненных CVE, но не раньше nvdInitialSyncLimit до now (записи, загруженные раньше, обновляются
+// в режиме NVD_SYNC_MODE=full), или now, если сохраненных CVE нет
+func initialSyncStart(earliest *time.Time, now time.Time) time.Time {
+	if earliest == nil || earliest.After(now) {
+		return now
+	}
+	if limit := now.Add(-nvdInitialSyncLimit); earliest.Before(limit) {
+		return limit
+	}
+	return *earliest
+}
+
+// Функция для обновления существующей записи cve_nvd
+func updateCVEDetails(ctx context.Context, dbpool *pgxpool.Pool, cveID string, cveItem *CVEItem, logger *log.Logger) error {
+	err := inTx(ctx, dbpool, func(tx pgx.Tx) error {
+		var cveNvdID int
+		err := tx.QueryRow(ctx, `
+			UPDATE cve_nvd
+			SET description = $2, last_fetched = $3, vuln_status = $4,
+				published = $5, last_modified = $6, rejection_reason = $7
+			WHERE cve_id = $1
+			RETURNING id
+		`, cveID, cveItem.Description(), time.Now(), cveItem.VulnStatus,
+			nvdTimeOrNil(cveItem.Published), nvdTimeOrNil(cveItem.LastModified), cveItem.RejectionReason()).Scan(&cveNvdID)
+		if err != nil {
+			return err
+		}
+		return saveCVEChildren(ctx, tx, cveNvdID, cveItem)
+	})
+	if err != nil {
+		logger.Printf("Ошибка при обновлении данных CVE для %s: %v\n", cveID, err)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyncWindows(t *testing.T) {
+	day := 24 * time.Hour
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		to   time.Time
+		want []syncWindow
+	}{
+		{"empty", from, nil},
+		{"reversed", from.Add(-day), nil},
+		{"one day", from.Add(day), []syncWindow{{from, from.Add(day)}}},
+		{"exactly 120 days", from.Add(nvdMaxModRange), []syncWindow{{from, from.Add(nvdMaxModRange)}}},
+		{"120 days and a second", from.Add(nvdMaxModRange + time.Second), []syncWindow{
+			{from, from.Add(nvdMaxModRange)},
+			{from.Add(nvdMaxModRange), from.Add(nvdMaxModRange + time.Second)},
+		}},
+		{"year", from.Add(365 * day), []syncWindow{
+			{from, from.Add(120 * day)},
+			{from.Add(120 * day), from.Add(240 * day)},
+			{from.Add(240 * day), from.Add(360 * day)},
+			{from.Add(360 * day), from.Add(365 * day)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := syncWindows(from, tt.to)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("syncWindows(%v, %v) = %v, want %v", from, tt.to, got, tt.want)
+			}
+			for _, w := range got {
+				if w.End.Sub(w.Start) > nvdMaxModRange {
+					t.Errorf("window %v longer than NVD allows", w)
+				}
+			}
+		})
+	}
+}
+
+func TestInitialSyncStart(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	tests := []struct {
+		name     string
+		earliest *time.Time
+		want     time.Time
+	}{
+		{"no saved CVE", nil, now},
+		{"recent", at(-48 * time.Hour), now.Add(-48 * time.Hour)},
+		{"years back", at(-3 * 365 * 24 * time.Hour), now.Add(-nvdInitialSyncLimit)},
+		{"in the future", at(time.Hour), now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := initialSyncStart(tt.earliest, now); !got.Equal(tt.want) {
+				t.Errorf("initialSyncStart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatNVDDate(t *testing.T) {
+	tests := []struct {
+		in   time.Time
+		want string
+	}{
+		{time.Date(2024, 1, 2, 3, 4, 5, 678e6, time.UTC), "2024-01-02T03:04:05.678+00:00"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("MSK", 3*3600)), "2024-01-02T00:04:05.000+00:00"},
+	}
+	for _, tt := range tests {
+		if got := formatNVDDate(tt.in); got != tt.want {
+			t.Errorf("formatNVDDate(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package cvss разбирает векторы CVSS v2, v3.0, v3.1 и v4.0 и вычисляет базовую оценку.
+//
+// Вектор v3.x и v4.0 начинается с префикса версии (CVSS:3.1/AV:N/...), вектор v2
+// префикса не имеет (AV:N/AC:L/Au:N/...) и может быть заключен в скобки, как в NVD.
+// В БДУ ФСТЭК векторы CVSS 3 встречаются без префикса, для них версия задается явно.
+//
+// Базовая оценка вычисляется по формулам спецификаций v2 и v3.x, для v4.0 - по таблице
+// макровекторов и алгоритму интерполяции спецификации FIRST (с учетом метрик угроз и среды,
+// если они заданы в векторе).
+package cvss
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Версии CVSS
+const (
+	V2  = "2.0"
+	V30 = "3.0"
+	V31 = "3.1"
+	V40 = "4.0"
+)
+
+// ErrScoreUnsupported - вычисление базовой оценки для версии не поддерживается
+var ErrScoreUnsupported = errors.New("вычисление оценки для этой версии CVSS не поддерживается")
+
+// Vector - разобранный вектор CVSS
+type Vector struct {
+	Version string
+	metrics map[string]string
+	order   []string
+}
+
+// Parse разбирает вектор, определяя версию по префиксу. Вектор без префикса считается CVSS v2.
+func Parse(s string) (*Vector, error) {
+	return ParseVersion(s, "")
+}
+
+// ParseVersion разбирает вектор; version используется, если в векторе нет префикса версии.
+// Пустая version для вектора без префикса означает CVSS v2.
+func ParseVersion(s, version string) (*Vector, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+	if s == "" {
+		return nil, errors.New("пустой вектор CVSS")
+	}
+
+	parts := strings.Split(s, "/")
+	if strings.HasPrefix(parts[0], "CVSS:") {
+		version = strings.TrimPrefix(parts[0], "CVSS:")
+		parts = parts[1:]
+	} else if version == "" {
+		version = V2
+	}
+
+	spec, ok := specs[version]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная версия CVSS: %s", version)
+	}
+
+	v := &Vector{Version: version, metrics: make(map[string]string, len(parts))}
+	for _, part := range parts {
+		key, value, ok := strings.Cut(part, ":")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("некорректная метрика %q в векторе %q", part, s)
+		}
+		if _, dup := v.metrics[key]; dup {
+			return nil, fmt.Errorf("повторная метрика %s в векторе %q", key, s)
+		}
+		allowed, known := spec.base[key]
+		if !known {
+			allowed, known = spec.optional[key]
+		}
+		if known && (len(value) != 1 || !strings.Contains(allowed, value)) {
+			return nil, fmt.Errorf("недопустимое значение %s:%s для CVSS %s", key, value, version)
+		}
+		v.metrics[key] = value
+		v.order = append(v.order, key)
+	}
+	for key := range spec.base {
+		if _, ok := v.metrics[key]; !ok {
+			return nil, fmt.Errorf("в векторе %q нет обязательной метрики %s", s, key)
+		}
+	}
+	return v, nil
+}
+
+// Metric возвращает значение метрики по ее сокращению (AV, AC, ...)
+func (v *Vector) Metric(key string) string {
+	return v.metrics[key]
+}
+
+// String возвращает вектор в каноническом виде: с префиксом версии для v3.x и v4.0
+func (v *Vector) String() string {
+	parts := make([]string, 0, len(v.order)+1)
+	if v.Version != V2 {
+		parts = append(parts, "CVSS:"+v.Version)
+	}
+	for _, key := range v.order {
+		parts = append(parts, key+":"+v.metrics[key])
+	}
+	return strings.Join(parts, "/")
+}
+
+// BaseScore вычисляет базовую оценку вектора
+func (v *Vector) BaseScore() (float64, error) {
+	switch v.Version {
+	case V2:
+		return v.baseScoreV2(), nil
+	case V30, V31:
+		return v.baseScoreV3(), nil
+	case V40:
+		return v.baseScoreV40(), nil
+	default:
+		return 0, ErrScoreUnsupported
+	}
+}
+
+// Severity возвращает качественную оценку опасности для базовой оценки версии version
+func Severity(version string, score float64) string {
+	if version == V2 {
+		switch {
+		case score < 4:
+			return "LOW"
+		case score < 7:
+			return "MEDIUM"
+		default:
+			return "HIGH"
+		}
+	}
+	switch {
+	case score == 0:
+		return "NONE"
+	case score < 4:
+		return "LOW"
+	case score < 7:
+		return "MEDIUM"
+	case score < 9:
+		return "HIGH"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// BaseMetrics - базовые метрики в виде, в котором их показывает OpenCVE
+type BaseMetrics struct {
+	AttackVector          string
+	AttackComplexity      string
+	PrivilegesRequired    string
+	UserInteraction       string
+	ConfidentialityImpact string
+	IntegrityImpact       string
+	AvailabilityImpact    string
+	Scope                 string
+}
+
+// BaseMetrics возвращает названия значений базовых метрик. Метрики, которых нет
+// в версии вектора (например, Scope в v2 и v4.0), остаются пустыми.
+func (v *Vector) BaseMetrics() BaseMetrics {
+	names := specs[v.Version].names
+	name := func(key string) string {
+		return names[key+":"+v.metrics[key]]
+	}
+
+	switch v.Version {
+	case V2:
+		return BaseMetrics{
+			AttackVector:          name("AV"),
+			AttackComplexity:      name("AC"),
+			ConfidentialityImpact: name("C"),
+			IntegrityImpact:       name("I"),
+			AvailabilityImpact:    name("A"),
+		}
+	case V40:
+		return BaseMetrics{
+			AttackVector:          name("AV"),
+			AttackComplexity:      name("AC"),
+			PrivilegesRequired:    name("PR"),
+			UserInteraction:       name("UI"),
+			ConfidentialityImpact: name("VC"),
+			IntegrityImpact:       name("VI"),
+			AvailabilityImpact:    name("VA"),
+		}
+	default:
+		return BaseMetrics{
+			AttackVector:          name("AV"),
+			AttackComplexity:      name("AC"),
+			PrivilegesRequired:    name("PR"),
+			UserInteraction:       name("UI"),
+			ConfidentialityImpact: name("C"),
+			IntegrityImpact:       name("I"),
+			AvailabilityImpact:    name("A"),
+			Scope:                 name("S"),
+		}
+	}
+}
+
+// spec - базовые метрики версии CVSS с допустимыми значениями, проверяемые необязательные
+// метрики, влияющие на оценку, и названия значений
+type spec struct {
+	base     map[string]string
+	optional map[string]string
+	names    map[string]string
+}
+
+var v3Spec = spec{
+	base: map[string]string{
+		"AV": "NALP", "AC": "LH", "PR": "NLH", "UI": "NR", "S": "UC", "C": "HLN", "I": "HLN", "A": "HLN",
+	},
+	names: map[string]string{
+		"AV:N": "Network", "AV:A": "Adjacent Network", "AV:L": "Local", "AV:P": "Physical",
+		"AC:L": "Low", "AC:H": "High",
+		"PR:N": "None", "PR:L": "Low", "PR:H": "High",
+		"UI:N": "None", "UI:R": "Required",
+		"S:U": "Unchanged", "S:C": "Changed",
+		"C:H": "High", "C:L": "Low", "C:N": "None",
+		"I:H": "High", "I:L": "Low", "I:N": "None",
+		"A:H": "High", "A:L": "Low", "A:N": "None",
+	},
+}
+
+var specs = map[string]spec{
+	V2: {
+		base: map[string]string{
+			"AV": "LAN", "AC": "HML", "Au": "MSN", "C": "NPC", "I": "NPC", "A": "NPC",
+		},
+		names: map[string]string{
+			"AV:L": "Local", "AV:A": "Adjacent Network", "AV:N": "Network",
+			"AC:H": "High", "AC:M": "Medium", "AC:L": "Low",
+			"C:N": "None", "C:P": "Partial", "C:C": "Complete",
+			"I:N": "None", "I:P": "Partial", "I:C": "Complete",
+			"A:N": "None", "A:P": "Partial", "A:C": "Complete",
+		},
+	},
+	V30: v3Spec,
+	V31: v3Spec,
+	V40: {
+		base: map[string]string{
+			"AV": "NALP", "AC": "LH", "AT": "NP", "PR": "NLH", "UI": "NPA",
+			"VC": "HLN", "VI": "HLN", "VA": "HLN", "SC": "HLN", "SI": "HLN", "SA": "HLN",
+		},
+		optional: map[string]string{
+			"E": "XAPU", "CR": "XHML", "IR": "XHML", "AR": "XHML",
+			"MAV": "XNALP", "MAC": "XLH", "MAT": "XNP", "MPR": "XNLH", "MUI": "XNPA",
+			"MVC": "XHLN", "MVI": "XHLN", "MVA": "XHLN", "MSC": "XHLN", "MSI": "XSHLN", "MSA": "XSHLN",
+		},
+		names: map[string]string{
+			"AV:N": "Network", "AV:A": "Adjacent Network", "AV:L": "Local", "AV:P": "Physical",
+			"AC:L": "Low", "AC:H": "High",
+			"PR:N": "None", "PR:L": "Low", "PR:H": "High",
+			"UI:N": "None", "UI:P": "Passive", "UI:A": "Active",
+			"VC:H": "High", "VC:L": "Low", "VC:N": "None",
+			"VI:H": "High", "VI:L": "Low", "VI:N": "None",
+			"VA:H": "High", "VA:L": "Low", "VA:N": "None",
+		},
+	},
+}
@@ -0,0 +1,92 @@
+package cvss
+
+import "math"
+
+// Весовые коэффициенты CVSS v2
+var v2Weights = map[string]float64{
+	"AV:L": 0.395, "AV:A": 0.646, "AV:N": 1.0,
+	"AC:H": 0.35, "AC:M": 0.61, "AC:L": 0.71,
+	"Au:M": 0.45, "Au:S": 0.56, "Au:N": 0.704,
+	"C:N": 0, "C:P": 0.275, "C:C": 0.660,
+	"I:N": 0, "I:P": 0.275, "I:C": 0.660,
+	"A:N": 0, "A:P": 0.275, "A:C": 0.660,
+}
+
+// Весовые коэффициенты CVSS v3.x. PR зависит от Scope и вычисляется отдельно.
+var v3Weights = map[string]float64{
+	"AV:N": 0.85, "AV:A": 0.62, "AV:L": 0.55, "AV:P": 0.2,
+	"AC:L": 0.77, "AC:H": 0.44,
+	"UI:N": 0.85, "UI:R": 0.62,
+	"C:H": 0.56, "C:L": 0.22, "C:N": 0,
+	"I:H": 0.56, "I:L": 0.22, "I:N": 0,
+	"A:H": 0.56, "A:L": 0.22, "A:N": 0,
+}
+
+func (v *Vector) weight(weights map[string]float64, key string) float64 {
+	return weights[key+":"+v.metrics[key]]
+}
+
+// baseScoreV2 вычисляет базовую оценку по формулам спецификации CVSS v2
+func (v *Vector) baseScoreV2() float64 {
+	w := func(key string) float64 { return v.weight(v2Weights, key) }
+
+	impact := 10.41 * (1 - (1-w("C"))*(1-w("I"))*(1-w("A")))
+	exploitability := 20 * w("AV") * w("AC") * w("Au")
+	f := 1.176
+	if impact == 0 {
+		f = 0
+	}
+	return math.Round(((0.6*impact)+(0.4*exploitability)-1.5)*f*10) / 10
+}
+
+// baseScoreV3 вычисляет базовую оценку по формулам спецификаций CVSS v3.0 и v3.1,
+// которые отличаются только правилом округления
+func (v *Vector) baseScoreV3() float64 {
+	w := func(key string) float64 { return v.weight(v3Weights, key) }
+	changed := v.metrics["S"] == "C"
+
+	var pr float64
+	switch v.metrics["PR"] {
+	case "N":
+		pr = 0.85
+	case "L":
+		pr = 0.62
+		if changed {
+			pr = 0.68
+		}
+	case "H":
+		pr = 0.27
+		if changed {
+			pr = 0.5
+		}
+	}
+
+	iss := 1 - (1-w("C"))*(1-w("I"))*(1-w("A"))
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	exploitability := 8.22 * w("AV") * w("AC") * pr * w("UI")
+
+	if impact <= 0 {
+		return 0
+	}
+	score := impact + exploitability
+	if changed {
+		score *= 1.08
+	}
+	return v.roundUp(math.Min(score, 10))
+}
+
+// roundUp округляет вверх до одного знака после запятой. В v3.1 округление
+// выполняется через целые числа, чтобы избежать ошибок представления чисел с плавающей точкой.
+func (v *Vector) roundUp(x float64) float64 {
+	if v.Version == V30 {
+		return math.Ceil(x*10) / 10
+	}
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
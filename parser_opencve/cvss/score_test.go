@@ -0,0 +1,124 @@
+package cvss
+
+import "testing"
+
+// Векторы и базовые оценки опубликованы в NVD для указанных CVE
+func TestBaseScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		vector  string
+		version string
+		score   float64
+	}{
+		{"CVE-2021-44228 v3.1, scope changed", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", "", 10.0},
+		{"CVE-2014-6271 v3.1", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "", 9.8},
+		{"CVE-2014-0160 v3.1", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", "", 7.5},
+		{"CVE-2017-0144 v3.0", "CVSS:3.0/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:H", "", 8.1},
+		{"CVE-2017-5753 v3.0, scope changed", "CVSS:3.0/AV:L/AC:H/PR:L/UI:N/S:C/C:H/I:N/A:N", "", 5.6},
+		{"v3.1 XSS, scope changed", "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", "", 6.1},
+		{"v3.1 stored XSS, scope changed", "CVSS:3.1/AV:N/AC:L/PR:L/UI:R/S:C/C:L/I:L/A:N", "", 5.4},
+		{"v3 without prefix from BDU", "AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", V30, 7.5},
+		{"CVE-2021-44228 v2", "AV:N/AC:M/Au:N/C:C/I:C/A:C", "", 9.3},
+		{"CVE-2014-6271 v2", "AV:N/AC:L/Au:N/C:C/I:C/A:C", "", 10.0},
+		{"CVE-2014-0160 v2", "AV:N/AC:L/Au:N/C:P/I:N/A:N", "", 5.0},
+		{"v2 in parentheses", "(AV:L/AC:L/Au:N/C:C/I:C/A:C)", "", 7.2},
+		{"v2 with single authentication", "AV:N/AC:L/Au:S/C:P/I:P/A:P", "", 6.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ParseVersion(tt.vector, tt.version)
+			if err != nil {
+				t.Fatalf("ParseVersion(%q): %v", tt.vector, err)
+			}
+			score, err := v.BaseScore()
+			if err != nil {
+				t.Fatalf("BaseScore(%q): %v", tt.vector, err)
+			}
+			if score != tt.score {
+				t.Errorf("BaseScore(%q) = %.1f, want %.1f", tt.vector, score, tt.score)
+			}
+		})
+	}
+}
+
+// Векторы CVSS v4.0 с оценками калькулятора FIRST (https://www.first.org/cvss/calculator/4.0)
+func TestBaseScoreV40(t *testing.T) {
+	tests := []struct {
+		name   string
+		vector string
+		score  float64
+	}{
+		{"highest", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:H/SI:H/SA:H", 10.0},
+		{"no impact", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:N/SC:N/SI:N/SA:N", 0.0},
+		{"vulnerable system only", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 9.3},
+		{"subsequent system only", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:N/SC:H/SI:H/SA:H", 7.9},
+		{"lowest non-zero", "CVSS:4.0/AV:P/AC:H/AT:P/PR:H/UI:A/VC:L/VI:N/VA:N/SC:N/SI:N/SA:N", 1.0},
+		{"local with user interaction", "CVSS:4.0/AV:L/AC:L/AT:N/PR:L/UI:P/VC:N/VI:H/VA:H/SC:N/SI:L/SA:L", 5.2},
+		{"unreported exploit maturity", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:H/SI:H/SA:H/E:U", 9.1},
+		{"modified safety impact", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:H/SI:H/SA:H/MVI:L/MSA:S", 9.8},
+		{"low security requirements", "CVSS:4.0/AV:N/AC:H/AT:N/PR:H/UI:N/VC:N/VI:N/VA:H/SC:H/SI:H/SA:H/CR:L/IR:L/AR:L", 5.8},
+		{"threat, environmental and supplemental metrics",
+			"CVSS:4.0/AV:L/AC:L/AT:N/PR:L/UI:P/VC:N/VI:H/VA:H/SC:N/SI:L/SA:L/E:P/CR:H/IR:M/AR:H/MAV:A/MAT:P/MPR:N/MVI:H/MVA:N/MSI:H/MSA:N/S:N/V:C/U:Amber", 4.7},
+		{"modified metrics with X",
+			"CVSS:4.0/AV:N/AC:L/AT:P/PR:L/UI:N/VC:N/VI:N/VA:N/SC:N/SI:N/SA:N/E:P/CR:X/IR:M/AR:X/MAV:N/MAC:H/MAT:X/MPR:L/MUI:X/MVC:L/MVI:N/MVA:H/MSC:L/MSI:S/MSA:S", 7.4},
+		{"modified safety with supplemental metrics",
+			"CVSS:4.0/AV:N/AC:L/AT:N/PR:L/UI:N/VC:N/VI:N/VA:N/SC:N/SI:N/SA:N/E:X/CR:H/IR:M/AR:L/MAV:P/MAC:H/MAT:X/MPR:N/MUI:P/MVC:N/MVI:H/MVA:N/MSC:N/MSI:X/MSA:S/S:P/AU:X/R:A/V:X/RE:M/U:Amber", 5.4},
+		{"modified to no impact",
+			"CVSS:4.0/AV:L/AC:L/AT:N/PR:H/UI:P/VC:H/VI:N/VA:L/SC:H/SI:N/SA:L/E:X/CR:M/IR:H/AR:H/MAV:N/MAC:X/MAT:N/MPR:N/MUI:A/MVC:N/MVI:X/MVA:N/MSC:N/MSI:X/MSA:N", 0.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Parse(tt.vector)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.vector, err)
+			}
+			score, err := v.BaseScore()
+			if err != nil {
+				t.Fatalf("BaseScore(%q): %v", tt.vector, err)
+			}
+			if score != tt.score {
+				t.Errorf("BaseScore(%q) = %.1f, want %.1f", tt.vector, score, tt.score)
+			}
+		})
+	}
+}
+
+// Таблица должна содержать оценку каждого допустимого макровектора: все сочетания уровней EQ1-EQ6,
+// кроме EQ3=2 с EQ6=0 (без высокого влияния на систему требования безопасности не повышают оценку)
+func TestV40MacroVectorScores(t *testing.T) {
+	levels := [6]int{3, 2, 3, 3, 3, 2}
+	var eq [6]int
+	var walk func(i int)
+	walk = func(i int) {
+		if i == len(eq) {
+			score, ok := v40MacroVectorScore(eq)
+			valid := !(eq[2] == 2 && eq[5] == 0)
+			if ok != valid {
+				t.Errorf("macro vector %v: present %v, want %v", eq, ok, valid)
+			}
+			if ok && (score <= 0 || score > 10) {
+				t.Errorf("macro vector %v score %.1f out of range", eq, score)
+			}
+			return
+		}
+		for level := 0; level < levels[i]; level++ {
+			eq[i] = level
+			walk(i + 1)
+		}
+	}
+	walk(0)
+	if len(v40MacroVectorScores) != 270 {
+		t.Errorf("got %d macro vectors, want 270", len(v40MacroVectorScores))
+	}
+}
+
+func TestParseV40InvalidOptionalMetric(t *testing.T) {
+	for _, vector := range []string{
+		"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N/E:Z",
+		"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N/MSC:S",
+	} {
+		if _, err := Parse(vector); err == nil {
+			t.Errorf("Parse(%q): want error", vector)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package cvss
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Оценки макровекторов CVSS v4.0 из таблицы спецификации FIRST (раздел 8.1).
+// Ключ - уровни эквивалентных наборов EQ1-EQ6, например "000000" для наиболее опасного макровектора.
+var v40MacroVectorScores = map[string]float64{
+	"000000": 10.0, "000001": 9.9, "000010": 9.8, "000011": 9.5, "000020": 9.5, "000021": 9.2,
+	"000100": 10.0, "000101": 9.6, "000110": 9.3, "000111": 8.7, "000120": 9.1, "000121": 8.1,
+	"000200": 9.3, "000201": 9.0, "000210": 8.9, "000211": 8.0, "000220": 8.1, "000221": 6.8,
+	"001000": 9.8, "001001": 9.5, "001010": 9.5, "001011": 9.2, "001020": 9.0, "001021": 8.4,
+	"001100": 9.3, "001101": 9.2, "001110": 8.9, "001111": 8.1, "001120": 8.1, "001121": 6.5,
+	"001200": 8.8, "001201": 8.0, "001210": 7.8, "001211": 7.0, "001220": 6.9, "001221": 4.8,
+	"002001": 9.2, "002011": 8.2, "002021": 7.2, "002101": 7.9, "002111": 6.9, "002121": 5.0,
+	"002201": 6.9, "002211": 5.5, "002221": 2.7, "010000": 9.9, "010001": 9.7, "010010": 9.5,
+	"010011": 9.2, "010020": 9.2, "010021": 8.5, "010100": 9.5, "010101": 9.1, "010110": 9.0,
+	"010111": 8.3, "010120": 8.4, "010121": 7.1, "010200": 9.2, "010201": 8.1, "010210": 8.2,
+	"010211": 7.1, "010220": 7.2, "010221": 5.3, "011000": 9.5, "011001": 9.3, "011010": 9.2,
+	"011011": 8.5, "011020": 8.5, "011021": 7.3, "011100": 9.2, "011101": 8.2, "011110": 8.0,
+	"011111": 7.2, "011120": 7.0, "011121": 5.9, "011200": 8.4, "011201": 7.0, "011210": 7.1,
+	"011211": 5.2, "011220": 5.0, "011221": 3.0, "012001": 8.6, "012011": 7.5, "012021": 5.2,
+	"012101": 7.1, "012111": 5.2, "012121": 2.9, "012201": 6.3, "012211": 2.9, "012221": 1.7,
+	"100000": 9.8, "100001": 9.5, "100010": 9.4, "100011": 8.7, "100020": 9.1, "100021": 8.1,
+	"100100": 9.4, "100101": 8.9, "100110": 8.6, "100111": 7.4, "100120": 7.7, "100121": 6.4,
+	"100200": 8.7, "100201": 7.5, "100210": 7.4, "100211": 6.3, "100220": 6.3, "100221": 4.9,
+	"101000": 9.4, "101001": 8.9, "101010": 8.8, "101011": 7.7, "101020": 7.6, "101021": 6.7,
+	"101100": 8.6, "101101": 7.6, "101110": 7.4, "101111": 5.8, "101120": 5.9, "101121": 5.0,
+	"101200": 7.2, "101201": 5.7, "101210": 5.7, "101211": 5.2, "101220": 5.2, "101221": 2.5,
+	"102001": 8.3, "102011": 7.0, "102021": 5.4, "102101": 6.5, "102111": 5.8, "102121": 2.6,
+	"102201": 5.3, "102211": 2.1, "102221": 1.3, "110000": 9.5, "110001": 9.0, "110010": 8.8,
+	"110011": 7.6, "110020": 7.6, "110021": 7.0, "110100": 9.0, "110101": 7.7, "110110": 7.5,
+	"110111": 6.2, "110120": 6.1, "110121": 5.3, "110200": 7.7, "110201": 6.6, "110210": 6.8,
+	"110211": 5.9, "110220": 5.2, "110221": 3.0, "111000": 8.9, "111001": 7.8, "111010": 7.6,
+	"111011": 6.7, "111020": 6.2, "111021": 5.8, "111100": 7.4, "111101": 5.9, "111110": 5.7,
+	"111111": 5.7, "111120": 4.7, "111121": 2.3, "111200": 6.1, "111201": 5.2, "111210": 5.7,
+	"111211": 2.9, "111220": 2.4, "111221": 1.6, "112001": 7.1, "112011": 5.9, "112021": 3.0,
+	"112101": 5.8, "112111": 2.6, "112121": 1.5, "112201": 2.3, "112211": 1.3, "112221": 0.6,
+	"200000": 9.3, "200001": 8.7, "200010": 8.6, "200011": 7.2, "200020": 7.5, "200021": 5.8,
+	"200100": 8.6, "200101": 7.4, "200110": 7.4, "200111": 6.1, "200120": 5.6, "200121": 3.4,
+	"200200": 7.0, "200201": 5.4, "200210": 5.2, "200211": 4.0, "200220": 4.0, "200221": 2.2,
+	"201000": 8.5, "201001": 7.5, "201010": 7.4, "201011": 5.5, "201020": 6.2, "201021": 5.1,
+	"201100": 7.2, "201101": 5.7, "201110": 5.5, "201111": 4.1, "201120": 4.6, "201121": 1.9,
+	"201200": 5.3, "201201": 3.6, "201210": 3.4, "201211": 1.9, "201220": 1.9, "201221": 0.8,
+	"202001": 6.4, "202011": 5.1, "202021": 2.0, "202101": 4.7, "202111": 2.1, "202121": 1.1,
+	"202201": 2.4, "202211": 0.9, "202221": 0.4, "210000": 8.8, "210001": 7.5, "210010": 7.3,
+	"210011": 5.3, "210020": 6.0, "210021": 5.0, "210100": 7.3, "210101": 5.5, "210110": 5.9,
+	"210111": 4.0, "210120": 4.1, "210121": 2.0, "210200": 5.4, "210201": 4.3, "210210": 4.5,
+	"210211": 2.2, "210220": 2.0, "210221": 1.1, "211000": 7.5, "211001": 5.5, "211010": 5.8,
+	"211011": 4.5, "211020": 4.0, "211021": 2.1, "211100": 6.1, "211101": 5.1, "211110": 4.8,
+	"211111": 1.8, "211120": 2.0, "211121": 0.9, "211200": 4.6, "211201": 1.8, "211210": 1.7,
+	"211211": 0.7, "211220": 0.8, "211221": 0.2, "212001": 5.3, "212011": 2.4, "212021": 1.4,
+	"212101": 2.4, "212111": 1.2, "212121": 0.5, "212201": 1.0, "212211": 0.3, "212221": 0.1,
+}
+
+// Порядок значений метрик CVSS v4.0 от наиболее к наименее опасному. Расстояние между значениями
+// (severity distance) - разность их позиций в этом порядке.
+var v40SeverityOrder = map[string]string{
+	"AV": "NALP", "PR": "NLH", "UI": "NPA",
+	"AC": "LH", "AT": "NP",
+	"VC": "HLN", "VI": "HLN", "VA": "HLN",
+	"SC": "HLN", "SI": "SHLN", "SA": "SHLN",
+	"CR": "HML", "IR": "HML", "AR": "HML",
+}
+
+// Наиболее опасные векторы каждого уровня эквивалентных наборов (таблицы 24-30 спецификации).
+// EQ3 и EQ6 зависят друг от друга и задаются совместно.
+var (
+	v40MaxEQ1 = [][]string{
+		{"AV:N/PR:N/UI:N"},
+		{"AV:A/PR:N/UI:N", "AV:N/PR:L/UI:N", "AV:N/PR:N/UI:P"},
+		{"AV:P/PR:N/UI:N", "AV:A/PR:L/UI:P"},
+	}
+	v40MaxEQ2 = [][]string{
+		{"AC:L/AT:N"},
+		{"AC:H/AT:N", "AC:L/AT:P"},
+	}
+	v40MaxEQ3EQ6 = [][][]string{
+		{
+			{"VC:H/VI:H/VA:H/CR:H/IR:H/AR:H"},
+			{"VC:H/VI:H/VA:L/CR:M/IR:M/AR:H", "VC:H/VI:H/VA:H/CR:M/IR:M/AR:M"},
+		},
+		{
+			{"VC:L/VI:H/VA:H/CR:H/IR:H/AR:H", "VC:H/VI:L/VA:H/CR:H/IR:H/AR:H"},
+			{"VC:H/VI:L/VA:H/CR:M/IR:H/AR:M", "VC:H/VI:L/VA:L/CR:M/IR:H/AR:H", "VC:L/VI:H/VA:H/CR:H/IR:M/AR:M",
+				"VC:L/VI:H/VA:L/CR:H/IR:M/AR:H", "VC:L/VI:L/VA:H/CR:H/IR:H/AR:M"},
+		},
+		{
+			nil,
+			{"VC:L/VI:L/VA:L/CR:H/IR:H/AR:H"},
+		},
+	}
+	v40MaxEQ4 = [][]string{
+		{"SC:H/SI:S/SA:S"},
+		{"SC:H/SI:H/SA:H"},
+		{"SC:L/SI:L/SA:L"},
+	}
+)
+
+// Наибольшее расстояние до наиболее опасного вектора на каждом уровне эквивалентного набора
+// (глубина макровектора), по которому расстояние вектора переводится в долю
+var (
+	v40DepthEQ1    = []float64{1, 4, 5}
+	v40DepthEQ2    = []float64{1, 2}
+	v40DepthEQ3EQ6 = [][]float64{{7, 6}, {8, 8}, {0, 10}}
+	v40DepthEQ4    = []float64{6, 5, 4}
+)
+
+// v40Metric возвращает значение метрики с учетом модифицированных метрик среды (MAV, MVC, ...)
+// и значений по умолчанию для незаданных метрик угроз и требований безопасности
+func (v *Vector) v40Metric(key string) string {
+	if modified := v.metrics["M"+key]; modified != "" && modified != "X" {
+		return modified
+	}
+	value := v.metrics[key]
+	if value == "" || value == "X" {
+		switch key {
+		case "E":
+			return "A"
+		case "CR", "IR", "AR":
+			return "H"
+		}
+	}
+	return value
+}
+
+// macroVectorV40 вычисляет уровни эквивалентных наборов EQ1-EQ6 вектора (раздел 8.2 спецификации)
+func (v *Vector) macroVectorV40() [6]int {
+	m := v.v40Metric
+	var eq [6]int
+
+	switch {
+	case m("AV") == "N" && m("PR") == "N" && m("UI") == "N":
+		eq[0] = 0
+	case (m("AV") == "N" || m("PR") == "N" || m("UI") == "N") && m("AV") != "P":
+		eq[0] = 1
+	default:
+		eq[0] = 2
+	}
+
+	if m("AC") != "L" || m("AT") != "N" {
+		eq[1] = 1
+	}
+
+	switch {
+	case m("VC") == "H" && m("VI") == "H":
+		eq[2] = 0
+	case m("VC") == "H" || m("VI") == "H" || m("VA") == "H":
+		eq[2] = 1
+	default:
+		eq[2] = 2
+	}
+
+	switch {
+	case m("SI") == "S" || m("SA") == "S":
+		eq[3] = 0
+	case m("SC") == "H" || m("SI") == "H" || m("SA") == "H":
+		eq[3] = 1
+	default:
+		eq[3] = 2
+	}
+
+	switch m("E") {
+	case "P":
+		eq[4] = 1
+	case "U":
+		eq[4] = 2
+	}
+
+	if !(m("CR") == "H" && m("VC") == "H") && !(m("IR") == "H" && m("VI") == "H") && !(m("AR") == "H" && m("VA") == "H") {
+		eq[5] = 1
+	}
+	return eq
+}
+
+// Функция для получения оценки макровектора; ok=false, если такого макровектора нет
+func v40MacroVectorScore(eq [6]int) (float64, bool) {
+	var key strings.Builder
+	for _, level := range eq {
+		key.WriteString(strconv.Itoa(level))
+	}
+	score, ok := v40MacroVectorScores[key.String()]
+	return score, ok
+}
+
+// baseScoreV40 вычисляет оценку CVSS v4.0 по алгоритму спецификации: берется оценка макровектора
+// и уменьшается на среднюю по эквивалентным наборам долю разницы с оценкой следующего,
+// менее опасного макровектора. Доля определяется расстоянием вектора до наиболее опасного
+// вектора своего макровектора. Метрики угроз и среды учитываются, если заданы в векторе.
+func (v *Vector) baseScoreV40() float64 {
+	m := v.v40Metric
+	if m("VC") == "N" && m("VI") == "N" && m("VA") == "N" && m("SC") == "N" && m("SI") == "N" && m("SA") == "N" {
+		return 0
+	}
+
+	eq := v.macroVectorV40()
+	value, _ := v40MacroVectorScore(eq)
+
+	// Разница с оценкой следующего макровектора по каждому эквивалентному набору,
+	// NaN - менее опасного макровектора нет
+	available := func(eq [6]int) float64 {
+		score, ok := v40MacroVectorScore(eq)
+		if !ok {
+			return math.NaN()
+		}
+		return value - score
+	}
+	lower := func(i int) [6]int {
+		next := eq
+		next[i]++
+		return next
+	}
+	availableEQ1 := available(lower(0))
+	availableEQ2 := available(lower(1))
+	availableEQ4 := available(lower(3))
+	availableEQ5 := available(lower(4))
+	var availableEQ3EQ6 float64
+	switch {
+	case eq[2] == 0 && eq[5] == 0:
+		// 00 -> 01 или 10, выбирается более опасный
+		availableEQ3EQ6 = math.Min(available(lower(5)), available(lower(2)))
+	case eq[2] == 1 && eq[5] == 0:
+		availableEQ3EQ6 = available(lower(5))
+	case eq[2] == 2:
+		availableEQ3EQ6 = math.NaN()
+	default:
+		availableEQ3EQ6 = available(lower(2))
+	}
+
+	// Расстояние до первого наиболее опасного вектора макровектора, от которого вектор не опаснее
+	var distEQ1, distEQ2, distEQ3EQ6, distEQ4 float64
+	distance := func(max string) (float64, bool) {
+		var sum float64
+		for _, part := range strings.Split(max, "/") {
+			key, maxValue, _ := strings.Cut(part, ":")
+			order := v40SeverityOrder[key]
+			d := strings.Index(order, m(key)) - strings.Index(order, maxValue)
+			if d < 0 {
+				return 0, false
+			}
+			sum += float64(d)
+		}
+		return sum, true
+	}
+search:
+	for _, max1 := range v40MaxEQ1[eq[0]] {
+		for _, max2 := range v40MaxEQ2[eq[1]] {
+			for _, max36 := range v40MaxEQ3EQ6[eq[2]][eq[5]] {
+				for _, max4 := range v40MaxEQ4[eq[3]] {
+					d1, ok1 := distance(max1)
+					d2, ok2 := distance(max2)
+					d36, ok36 := distance(max36)
+					d4, ok4 := distance(max4)
+					if ok1 && ok2 && ok36 && ok4 {
+						distEQ1, distEQ2, distEQ3EQ6, distEQ4 = d1, d2, d36, d4
+						break search
+					}
+				}
+			}
+		}
+	}
+
+	// Средняя по наборам, для которых есть менее опасный макровектор, доля разницы оценок.
+	// Для EQ5 (одна метрика E) расстояние внутри макровектора всегда нулевое.
+	var sum float64
+	var n int
+	for _, part := range []struct{ available, distance, depth float64 }{
+		{availableEQ1, distEQ1, v40DepthEQ1[eq[0]]},
+		{availableEQ2, distEQ2, v40DepthEQ2[eq[1]]},
+		{availableEQ3EQ6, distEQ3EQ6, v40DepthEQ3EQ6[eq[2]][eq[5]]},
+		{availableEQ4, distEQ4, v40DepthEQ4[eq[3]]},
+		{availableEQ5, 0, 1},
+	} {
+		if math.IsNaN(part.available) {
+			continue
+		}
+		n++
+		sum += part.available * part.distance / part.depth
+	}
+	if n > 0 {
+		value -= sum / float64(n)
+	}
+	return math.Round(math.Max(0, math.Min(value, 10))*10) / 10
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// EmptyCveDataError - для CVE не получено ни одной метрики: нет векторов CVSS,
+// а OpenCVE (API или страница) не вернул данных или не используется. Такой результат не сохраняется в cve_opencve.
+type EmptyCveDataError struct {
+	CVEID  string
+	Source string // источник, вернувший пустой результат; пустой, если OpenCVE не запрашивался
+}
+
+func (e *EmptyCveDataError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%s: метрики CVSS не найдены", e.CVEID)
+	}
+	return fmt.Sprintf("%s: метрики CVSS не найдены (%s)", e.CVEID, e.Source)
+}
+
+// IsEmpty сообщает, что ни одна базовая метрика не заполнена
+func (d CveData) IsEmpty() bool {
+	return d.AttackVector == "" && d.AttackComplexity == "" && d.PrivilegesRequired == "" &&
+		d.UserInteraction == "" && d.ConfidentialityImpact == "" && d.IntegrityImpact == "" &&
+		d.AvailabilityImpact == "" && d.Scope == ""
+}
+
+// Функция для создания таблицы неудачных попыток получения метрик и удаления
+// ранее сохраненных записей без метрик
+func createCveOpencveFailureTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cve_opencve_failure (
+			cve_id TEXT PRIMARY KEY,
+			empty_result BOOLEAN NOT NULL,
+			error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 1,
+			first_failed TIMESTAMP NOT NULL,
+			last_failed TIMESTAMP NOT NULL
+		);`,
+		`DELETE FROM cve_opencve
+		WHERE COALESCE(attack_vector, '') = '' AND COALESCE(attack_complexity, '') = ''
+		AND COALESCE(privileges_required, '') = '' AND COALESCE(user_interaction, '') = ''
+		AND COALESCE(confidentiality_impact, '') = '' AND COALESCE(integrity_impact, '') = ''
+		AND COALESCE(availability_impact, '') = '' AND COALESCE(scope, '') = '';`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для записи неудачной попытки получения метрик CVE
+func recordFailure(ctx context.Context, dbpool *pgxpool.Pool, cveID string, failure error) error {
+	var emptyErr *EmptyCveDataError
+	now := time.Now()
+	_, err := dbpool.Exec(ctx, `
+		INSERT INTO cve_opencve_failure (cve_id, empty_result, error, attempts, first_failed, last_failed)
+		VALUES ($1, $2, $3, 1, $4, $4)
+		ON CONFLICT (cve_id) DO UPDATE
+		SET empty_result = EXCLUDED.empty_result, error = EXCLUDED.error,
+			attempts = cve_opencve_failure.attempts + 1, last_failed = EXCLUDED.last_failed
+	`, cveID, errors.As(failure, &emptyErr), failure.Error(), now)
+	return err
+}
+
+// Функция для удаления записи о неудачных попытках после успешного получения метрик
+func clearFailure(ctx context.Context, dbpool *pgxpool.Pool, cveID string) error {
+	_, err := dbpool.Exec(ctx, `DELETE FROM cve_opencve_failure WHERE cve_id = $1`, cveID)
+	return err
+}
@@ -2,20 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
-	"net/http"
 	"os"
-	"strings"
-	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 )
 
+// Период обновления метрик CVE по умолчанию
+const openCVEDefaultRefreshInterval = 7 * 24 * time.Hour
+
+// Функция для получения периода обновления метрик из переменной OPENCVE_REFRESH_INTERVAL
+func openCVERefreshInterval(logger *log.Logger) time.Duration {
+	value := os.Getenv("OPENCVE_REFRESH_INTERVAL")
+	if value == "" {
+		return openCVEDefaultRefreshInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		logger.Printf("Некорректное значение OPENCVE_REFRESH_INTERVAL %q, используется %s\n", value, openCVEDefaultRefreshInterval)
+		return openCVEDefaultRefreshInterval
+	}
+	return interval
+}
+
 func main() {
 	// Загрузка переменных окружения из .env файла, который находится в поддиректории
 	err := godotenv.Load()
@@ -59,7 +73,7 @@ func main() {
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS cve_opencve (
 		id SERIAL PRIMARY KEY,
-		vulnerability_id INTEGER,
+		cve_id TEXT NOT NULL UNIQUE,
 		attack_vector TEXT,
 		attack_complexity TEXT,
 		privileges_required TEXT,
@@ -68,101 +82,206 @@ func main() {
 		integrity_impact TEXT,
 		availability_impact TEXT,
 		scope TEXT,
-		FOREIGN KEY(vulnerability_id) REFERENCES vulnerability(id)
+		cvss_version TEXT,
+		vector_string TEXT,
+		base_score NUMERIC(3, 1),
+		base_severity TEXT,
+		source TEXT,
+		last_fetched TIMESTAMP
 	);`
 	_, err = dbpool.Exec(context.Background(), createTableSQL)
 	if err != nil {
 		logger.Fatalf("Не удалось создать таблицу: %v\n", err)
 	}
 
-	logger.Println("Успешное подключение и создание таблицы!")
+	// Столбцы вектора и оценки CVSS, добавленные после первой версии таблицы
+	migrateTableSQL := []string{
+		`ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS cvss_version TEXT;`,
+		`ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS vector_string TEXT;`,
+		`ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS base_score NUMERIC(3, 1);`,
+		`ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS base_severity TEXT;`,
+		`ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS source TEXT;`,
+		`ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS last_fetched TIMESTAMP;`,
+		// Метрики хранятся по CVE. Прежние записи относились к произвольной CVE уязвимости,
+		// поэтому они удаляются и вычисляются заново.
+		`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'cve_opencve' AND column_name = 'vulnerability_id') THEN
+				ALTER TABLE cve_opencve ADD COLUMN IF NOT EXISTS cve_id TEXT;
+				DELETE FROM cve_opencve WHERE cve_id IS NULL;
+				ALTER TABLE cve_opencve DROP COLUMN vulnerability_id;
+				ALTER TABLE cve_opencve ALTER COLUMN cve_id SET NOT NULL;
+				ALTER TABLE cve_opencve ADD CONSTRAINT cve_opencve_cve_id_key UNIQUE (cve_id);
+			END IF;
+		END $$;`,
+		// Наихудший случай по уязвимости: метрики CVE с наибольшей базовой оценкой
+		`CREATE OR REPLACE VIEW vulnerability_opencve AS
+		SELECT DISTINCT ON (cve_identifier.vulnerability_id)
+			cve_identifier.vulnerability_id, cve_opencve.cve_id,
+			cve_opencve.attack_vector, cve_opencve.attack_complexity, cve_opencve.privileges_required,
+			cve_opencve.user_interaction, cve_opencve.confidentiality_impact, cve_opencve.integrity_impact,
+			cve_opencve.availability_impact, cve_opencve.scope, cve_opencve.cvss_version, cve_opencve.vector_string,
+			cve_opencve.base_score, cve_opencve.base_severity, cve_opencve.source
+		FROM cve_identifier
+		JOIN cve_opencve ON cve_opencve.cve_id = cve_identifier.link
+		ORDER BY cve_identifier.vulnerability_id, cve_opencve.base_score DESC NULLS LAST, cve_opencve.cve_id;`,
+	}
+	for _, query := range migrateTableSQL {
+		_, err = dbpool.Exec(context.Background(), query)
+		if err != nil {
+			logger.Fatalf("Не удалось обновить таблицу: %v\n", err)
+		}
+	}
 
-	// Получение всех уязвимостей
-	rows, err := dbpool.Query(context.Background(), `SELECT id, identifier FROM vulnerability ORDER BY identifier DESC`)
+	err = createCveOpencveFailureTable(context.Background(), dbpool)
 	if err != nil {
-		logger.Fatalf("Не удалось выполнить запрос: %v\n", err)
+		logger.Fatalf("Не удалось создать таблицу cve_opencve_failure: %v\n", err)
 	}
-	defer rows.Close()
 
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10) // Ограничиваем количество параллельных запросов
-
-	// Обработка каждой уязвимости
-	for rows.Next() {
-		var id int
-		var identifier string
-		if err := rows.Scan(&id, &identifier); err != nil {
-			logger.Fatalf("Не удалось прочитать строку: %v\n", err)
-		}
+	err = createCpeProductTables(context.Background(), dbpool)
+	if err != nil {
+		logger.Fatalf("Не удалось создать таблицы производителей и продуктов: %v\n", err)
+	}
 
-		// Проверка, есть ли уже запись о данной уязвимости
-		var exists bool
-		err := dbpool.QueryRow(context.Background(), `SELECT EXISTS(SELECT 1 FROM cve_opencve WHERE vulnerability_id=$1)`, id).Scan(&exists)
-		if err != nil {
-			logger.Printf("Не удалось выполнить запрос на проверку существования записи для уязвимости %d: %v\n", id, err)
-			continue
-		}
+	// API OpenCVE используется только для уязвимостей без векторов CVSS и только если заданы учетные данные
+	openCVE := newOpenCVEClientFromEnv()
+	if openCVE == nil {
+		logger.Println("Учетные данные OpenCVE не заданы, метрики вычисляются только из векторов БДУ и NVD")
+	}
 
-		if exists {
-			logger.Printf("Запись для уязвимости %d уже существует, пропускаем\n", id)
-			continue
-		}
+	// Разбор страниц opencve.io включается OPENCVE_SCRAPE=true для CVE, метрики которых не удалось
+	// получить ни из одного вектора
+	scraper := newOpenCVEScraperFromEnv()
+	if scraper != nil {
+		logger.Println("Для CVE без векторов CVSS метрики берутся со страниц OpenCVE")
+	}
 
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(id int, identifier string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-
-			// Получение CVE из таблицы cve_identifier
-			var cveLink string
-			err = dbpool.QueryRow(context.Background(), `SELECT link FROM cve_identifier WHERE vulnerability_id = $1 LIMIT 1`, id).Scan(&cveLink)
-			if err != nil {
-				logger.Printf("Не удалось выполнить запрос для уязвимости %d: %v\n", id, err)
-				return
-			}
+	logger.Println("Успешное подключение и создание таблицы!")
 
-			// Формирование полного URL
-			fullURL := fmt.Sprintf("https://www.opencve.io/cve/%s", strings.TrimSpace(cveLink))
+	refreshInterval := openCVERefreshInterval(logger)
+	logger.Printf("Период обновления метрик CVE: %s\n", refreshInterval)
 
-			// Веб-скрапинг для получения CVE данных
-			cveData, err := scrapeCveData(fullURL, logger)
-			if err != nil {
-				logger.Printf("Ошибка при скрапинге CVE данных для уязвимости %d: %v\n", id, err)
-				return
-			}
+	cveIDs, err := fetchWorkList(context.Background(), dbpool, time.Now().Add(-refreshInterval))
+	if err != nil {
+		logger.Fatalf("Не удалось получить список CVE: %v\n", err)
+	}
+	logger.Printf("CVE для обработки: %d\n", len(cveIDs))
 
-			// Сохранение данных в таблицу cve_opencve
-			_, err = dbpool.Exec(
-				context.Background(),
-				`INSERT INTO cve_opencve (
-					vulnerability_id, attack_vector, attack_complexity, privileges_required, user_interaction,
-					confidentiality_impact, integrity_impact, availability_impact, scope
-				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-				id, cveData.AttackVector, cveData.AttackComplexity, cveData.PrivilegesRequired, cveData.UserInteraction,
-				cveData.ConfidentialityImpact, cveData.IntegrityImpact, cveData.AvailabilityImpact, cveData.Scope)
-			if err != nil {
-				logger.Printf("Ошибка при вставке CVE данных для уязвимости %d: %v\n", id, err)
-				return
-			}
+	store := &cveStore{dbpool: dbpool, openCVE: openCVE, scraper: scraper, logger: logger}
+	result := runWorkers(context.Background(), cveIDs, openCVEWorkers, store.Fetch, store.Save)
+	logger.Printf("Обработано CVE: %d, с ошибками: %d, пропущено: %d\n", result.Processed, result.Failed, result.Skipped)
 
-			logger.Printf("Данные для уязвимости %d успешно вставлены\n", id)
+	logger.Println("Получение и сохранение данных CVE успешно завершены!")
+}
 
-			// Случайная задержка между запросами от 1 миллисекунды до 2 секунд
+// Функция для получения метрик CVE: из векторов CVSS NVD и выгрузки БДУ, а при их отсутствии из API OpenCVE.
+// Если ни один вектор не дал оценки и включен разбор страниц (scraper не nil), метрики берутся
+// со страницы CVE на opencve.io. Производители и продукты берутся из конфигураций CPE NVD, а если их нет,
+// из OpenCVE. Если метрики не найдены, возвращается *EmptyCveDataError.
+func fetchCveData(ctx context.Context, dbpool *pgxpool.Pool, openCVE *OpenCVEClient, scraper *OpenCVEScraper, cveID string, logger *log.Logger) (CveData, error) {
+	candidates, err := fetchVectorCandidates(ctx, dbpool, cveID, logger)
+	if err != nil {
+		return CveData{}, fmt.Errorf("не удалось получить векторы CVSS: %w", err)
+	}
+	cveData, ok := cveDataFromVectors(candidates, logger)
+	products := fetchNVDProducts(ctx, dbpool, cveID, logger)
+	productsSource := sourceNVD
+	emptySource := ""
+
+	if openCVE != nil && (!ok || len(products) == 0) {
+		// Получение записи CVE из API OpenCVE
+		cve, err := openCVE.GetCVE(ctx, cveID, logger)
+		switch {
+		case err != nil && ok:
+			// Метрики уже получены, без производителей из OpenCVE запись все равно сохраняется
+			logger.Printf("Не удалось получить производителей %s из OpenCVE: %v\n", cveID, err)
+		case errors.Is(err, errCVENotFound):
+			emptySource = sourceOpenCVE
+		case err != nil && scraper == nil:
+			return CveData{}, fmt.Errorf("ошибка при получении из OpenCVE: %w", err)
+		case err != nil:
+			logger.Printf("Ошибка при получении %s из OpenCVE, метрики будут взяты со страницы: %v\n", cveID, err)
+		default:
+			// Случайная задержка между запросами к API от 1 миллисекунды до 2 секунд
 			randomDelay := time.Duration(rand.Intn(2000-1)+1) * time.Millisecond
 			time.Sleep(randomDelay)
-		}(id, identifier)
+
+			if !ok {
+				cveData, ok = cveDataFromVectors(cve.VectorCandidates(), logger)
+				emptySource = sourceOpenCVE
+			}
+			if len(products) == 0 {
+				products = cve.Products()
+				productsSource = sourceOpenCVE
+			}
+		}
+	}
+
+	// Страница OpenCVE разбирается, только если ни один вектор не дал оценки
+	if !ok && scraper != nil {
+		cveData, err = scraper.Scrape(ctx, cveID, logger)
+		switch {
+		case errors.Is(err, errCVENotFound):
+			return CveData{}, &EmptyCveDataError{CVEID: cveID, Source: sourceOpenCVEPage}
+		case err != nil:
+			return CveData{}, fmt.Errorf("ошибка при получении страницы OpenCVE: %w", err)
+		}
+		ok = !cveData.IsEmpty()
+		emptySource = sourceOpenCVEPage
+	}
+	if !ok {
+		return CveData{}, &EmptyCveDataError{CVEID: cveID, Source: emptySource}
 	}
 
-	wg.Wait()
+	cveData.Products = products
+	cveData.ProductsSource = productsSource
+	return cveData, nil
+}
 
-	if err := rows.Err(); err != nil {
-		logger.Fatalf("Ошибка при чтении строк: %v\n", err)
+// Функция для вставки или обновления метрик CVE и ее связей с производителями и продуктами.
+// Результат без метрик не сохраняется; пустой список продуктов не заменяет ранее сохраненный.
+func saveCveData(ctx context.Context, dbpool *pgxpool.Pool, cveID string, cveData CveData) error {
+	if cveData.IsEmpty() {
+		return &EmptyCveDataError{CVEID: cveID, Source: cveData.Source}
 	}
 
-	logger.Println("Скрапинг и сохранение данных CVE успешно завершены!")
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO cve_opencve (
+			cve_id, attack_vector, attack_complexity, privileges_required, user_interaction,
+			confidentiality_impact, integrity_impact, availability_impact, scope,
+			cvss_version, vector_string, base_score, base_severity, source, last_fetched
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (cve_id) DO UPDATE
+		SET attack_vector = EXCLUDED.attack_vector, attack_complexity = EXCLUDED.attack_complexity,
+			privileges_required = EXCLUDED.privileges_required, user_interaction = EXCLUDED.user_interaction,
+			confidentiality_impact = EXCLUDED.confidentiality_impact, integrity_impact = EXCLUDED.integrity_impact,
+			availability_impact = EXCLUDED.availability_impact, scope = EXCLUDED.scope,
+			cvss_version = EXCLUDED.cvss_version, vector_string = EXCLUDED.vector_string,
+			base_score = EXCLUDED.base_score, base_severity = EXCLUDED.base_severity,
+			source = EXCLUDED.source, last_fetched = EXCLUDED.last_fetched
+	`, cveID, cveData.AttackVector, cveData.AttackComplexity, cveData.PrivilegesRequired, cveData.UserInteraction,
+		cveData.ConfidentialityImpact, cveData.IntegrityImpact, cveData.AvailabilityImpact, cveData.Scope,
+		cveData.CVSSVersion, cveData.VectorString, cveData.BaseScore, cveData.BaseSeverity, cveData.Source, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if len(cveData.Products) > 0 {
+		err = saveProducts(ctx, tx, cveID, cveData.Products, cveData.ProductsSource)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
 }
 
+// CveData - базовые метрики CVSS, вектор, из которого они получены, и затронутые продукты
 type CveData struct {
 	AttackVector          string
 	AttackComplexity      string
@@ -172,50 +291,11 @@ type CveData struct {
 	IntegrityImpact       string
 	AvailabilityImpact    string
 	Scope                 string
-}
-
-// scrapeCveData выполняет веб-скрапинг данных CVE с указанного URL с повторными попытками
-func scrapeCveData(cveURL string, logger *log.Logger) (CveData, error) {
-	var cveData CveData
-	const maxRetries = 5
-
-	for i := 0; i < maxRetries; i++ {
-		// Выполнение запроса к странице
-		res, err := http.Get(cveURL)
-		if err != nil {
-			logger.Printf("Попытка %d: ошибка при получении URL: %v\n", i+1, err)
-			time.Sleep(time.Duration(rand.Intn(2000-1)+1) * time.Millisecond)
-			continue
-		}
-
-		if res.StatusCode != 200 {
-			logger.Printf("Попытка %d: ошибка: получен ненормативный код ответа %d\n", i+1, res.StatusCode)
-			res.Body.Close()
-			time.Sleep(time.Duration(rand.Intn(2000-1)+1) * time.Millisecond)
-			continue
-		}
-
-		// Парсинг HTML
-		doc, err := goquery.NewDocumentFromReader(res.Body)
-		res.Body.Close()
-		if err != nil {
-			logger.Printf("Попытка %d: ошибка при парсинге HTML: %v\n", i+1, err)
-			time.Sleep(time.Duration(rand.Intn(2000-1)+1) * time.Millisecond)
-			continue
-		}
-
-		// Извлечение данных из HTML
-		cveData.AttackVector = doc.Find("h4:contains('Attack Vector') .pull-right").Text()
-		cveData.AttackComplexity = doc.Find("h4:contains('Attack Complexity') .pull-right").Text()
-		cveData.PrivilegesRequired = doc.Find("h4:contains('Privileges Required') .pull-right").Text()
-		cveData.UserInteraction = doc.Find("h4:contains('User Interaction') .pull-right").Text()
-		cveData.ConfidentialityImpact = doc.Find("h4:contains('Confidentiality Impact') .pull-right").Text()
-		cveData.IntegrityImpact = doc.Find("h4:contains('Integrity Impact') .pull-right").Text()
-		cveData.AvailabilityImpact = doc.Find("h4:contains('Availability Impact') .pull-right").Text()
-		cveData.Scope = doc.Find("h4:contains('Scope') .pull-right").Text()
-
-		return cveData, nil
-	}
-
-	return cveData, fmt.Errorf("превышено максимальное количество попыток для URL: %s", cveURL)
+	CVSSVersion           string
+	VectorString          string
+	BaseScore             *float64
+	BaseSeverity          string
+	Source                string
+	Products              []CPEProduct
+	ProductsSource        string
 }
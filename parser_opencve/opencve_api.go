@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"parser_opencve/cvss"
+)
+
+const (
+	// Адрес OpenCVE по умолчанию, для собственного экземпляра задается OPENCVE_URL
+	openCVEDefaultURL = "https://app.opencve.io"
+	// Время ожидания одного запроса к API
+	openCVERequestTimeout = 30 * time.Second
+	// Количество попыток запроса
+	openCVEMaxRetries = 5
+)
+
+// errCVENotFound - CVE нет в базе OpenCVE
+var errCVENotFound = errors.New("CVE не найдена в OpenCVE")
+
+// OpenCVEClient - клиент REST API OpenCVE (opencve.io или собственный экземпляр)
+type OpenCVEClient struct {
+	baseURL    string
+	username   string
+	password   string
+	token      string
+	httpClient *http.Client
+}
+
+// Функция для создания клиента OpenCVE из переменных окружения. Возвращает nil, если учетные данные не заданы:
+// API OpenCVE не отвечает на запросы без авторизации.
+func newOpenCVEClientFromEnv() *OpenCVEClient {
+	client := &OpenCVEClient{
+		baseURL:    strings.TrimRight(os.Getenv("OPENCVE_URL"), "/"),
+		username:   os.Getenv("OPENCVE_USERNAME"),
+		password:   os.Getenv("OPENCVE_PASSWORD"),
+		token:      os.Getenv("OPENCVE_TOKEN"),
+		httpClient: &http.Client{Timeout: openCVERequestTimeout},
+	}
+	if client.token == "" && (client.username == "" || client.password == "") {
+		return nil
+	}
+	if client.baseURL == "" {
+		client.baseURL = openCVEDefaultURL
+	}
+	return client
+}
+
+// OpenCVECVE - ответ /api/cve/<id>. OpenCVE 2.x возвращает метрики в metrics,
+// OpenCVE 1.x - оценки в cvss и исходную запись NVD в raw_nvd_data. Формат vendors
+// также различается, см. Products.
+type OpenCVECVE struct {
+	ID      string                   `json:"id"`
+	CVEID   string                   `json:"cve_id"`
+	Metrics map[string]OpenCVEMetric `json:"metrics"`
+	RawNVD  *OpenCVERawNVD           `json:"raw_nvd_data"`
+	Vendors json.RawMessage          `json:"vendors"`
+}
+
+// OpenCVEMetric - метрика OpenCVE 2.x; для CVSS data содержит вектор и оценку
+type OpenCVEMetric struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// OpenCVERawNVD - метрики CVSS записи NVD в формате JSON 1.1 из OpenCVE 1.x
+type OpenCVERawNVD struct {
+	Impact struct {
+		BaseMetricV3 struct {
+			CVSSV3 struct {
+				Version      string   `json:"version"`
+				VectorString string   `json:"vectorString"`
+				BaseScore    *float64 `json:"baseScore"`
+			} `json:"cvssV3"`
+		} `json:"baseMetricV3"`
+		BaseMetricV2 struct {
+			CVSSV2 struct {
+				VectorString string   `json:"vectorString"`
+				BaseScore    *float64 `json:"baseScore"`
+			} `json:"cvssV2"`
+		} `json:"baseMetricV2"`
+	} `json:"impact"`
+}
+
+// Версии CVSS по ключам metrics OpenCVE 2.x
+var openCVEMetricVersions = map[string]string{
+	"cvssV4_0": cvss.V40,
+	"cvssV3_1": cvss.V31,
+	"cvssV3_0": cvss.V30,
+	"cvssV2_0": cvss.V2,
+}
+
+// VectorCandidates возвращает векторы CVSS записи OpenCVE
+func (c *OpenCVECVE) VectorCandidates() []vectorCandidate {
+	var candidates []vectorCandidate
+	for key, metric := range c.Metrics {
+		version, ok := openCVEMetricVersions[key]
+		if !ok || len(metric.Data) == 0 {
+			continue
+		}
+		var data struct {
+			Vector string   `json:"vector"`
+			Score  *float64 `json:"score"`
+		}
+		if err := json.Unmarshal(metric.Data, &data); err != nil || data.Vector == "" {
+			continue
+		}
+		candidates = append(candidates, vectorCandidate{Source: sourceOpenCVE, Vector: data.Vector, Version: version, Score: data.Score})
+	}
+
+	if c.RawNVD != nil {
+		v3 := c.RawNVD.Impact.BaseMetricV3.CVSSV3
+		if v3.VectorString != "" {
+			candidates = append(candidates, vectorCandidate{Source: sourceOpenCVE, Vector: v3.VectorString, Version: v3.Version, Score: v3.BaseScore})
+		}
+		v2 := c.RawNVD.Impact.BaseMetricV2.CVSSV2
+		if v2.VectorString != "" {
+			candidates = append(candidates, vectorCandidate{Source: sourceOpenCVE, Vector: v2.VectorString, Version: cvss.V2, Score: v2.BaseScore})
+		}
+	}
+
+	sortCandidates(candidates)
+	return candidates
+}
+
+// Функция для получения записи CVE из API OpenCVE с повторными попытками
+func (c *OpenCVEClient) GetCVE(ctx context.Context, cveID string, logger *log.Logger) (*OpenCVECVE, error) {
+	endpoint := fmt.Sprintf("%s/api/cve/%s", c.baseURL, url.PathEscape(cveID))
+
+	var lastErr error
+	for i := 0; i < openCVEMaxRetries; i++ {
+		if i > 0 {
+			// Случайная задержка перед повторной попыткой от 1 до 2 секунд, умноженная на номер попытки
+			delay := time.Duration(i) * time.Duration(1000+rand.Intn(1000)) * time.Millisecond
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		cve, retry, err := c.doGetCVE(ctx, endpoint)
+		if err == nil {
+			return cve, nil
+		}
+		if !retry {
+			return nil, err
+		}
+		logger.Printf("Попытка %d: ошибка при запросе %s: %v\n", i+1, endpoint, err)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("превышено максимальное количество попыток для %s: %w", endpoint, lastErr)
+}
+
+// Функция для выполнения одного запроса к API. Возвращает признак того, что запрос можно повторить.
+func (c *OpenCVEClient) doGetCVE(ctx context.Context, endpoint string) (*OpenCVECVE, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, false, errCVENotFound
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return nil, false, fmt.Errorf("доступ к API OpenCVE запрещен (код %d), проверьте учетные данные", res.StatusCode)
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return nil, true, fmt.Errorf("получен код ответа %d", res.StatusCode)
+	case res.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("получен ненормативный код ответа %d", res.StatusCode)
+	}
+
+	var cve OpenCVECVE
+	if err := json.NewDecoder(res.Body).Decode(&cve); err != nil {
+		return nil, true, fmt.Errorf("ошибка при разборе JSON ответа OpenCVE: %w", err)
+	}
+	return &cve, false, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Разделитель производителя и продукта в списке vendors OpenCVE 2.x
+const openCVEProductSeparator = "$PRODUCT$"
+
+// CPEProduct - производитель и продукт в терминах CPE; Product пустой, если известен только производитель
+type CPEProduct struct {
+	Vendor  string
+	Product string
+}
+
+// Функция для создания справочников производителей и продуктов, связей с CVE
+// и представлений для поиска уязвимостей БДУ по производителю
+func createCpeProductTables(ctx context.Context, dbpool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS cpe_vendor (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE
+		);`,
+		`CREATE TABLE IF NOT EXISTS cpe_product (
+			id SERIAL PRIMARY KEY,
+			vendor_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			UNIQUE(vendor_id, name),
+			FOREIGN KEY(vendor_id) REFERENCES cpe_vendor(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS cve_cpe_vendor (
+			cve_id TEXT NOT NULL,
+			vendor_id INTEGER NOT NULL,
+			source TEXT,
+			PRIMARY KEY(cve_id, vendor_id),
+			FOREIGN KEY(vendor_id) REFERENCES cpe_vendor(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS cve_cpe_vendor_vendor_id_idx ON cve_cpe_vendor (vendor_id);`,
+		`CREATE TABLE IF NOT EXISTS cve_cpe_product (
+			cve_id TEXT NOT NULL,
+			product_id INTEGER NOT NULL,
+			source TEXT,
+			PRIMARY KEY(cve_id, product_id),
+			FOREIGN KEY(product_id) REFERENCES cpe_product(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS cve_cpe_product_product_id_idx ON cve_cpe_product (product_id);`,
+		// Производители уязвимостей БДУ по всем их CVE
+		`CREATE OR REPLACE VIEW vulnerability_vendor AS
+		SELECT DISTINCT cve_identifier.vulnerability_id, cpe_vendor.id AS vendor_id, cpe_vendor.name AS vendor
+		FROM cve_identifier
+		JOIN cve_cpe_vendor ON cve_cpe_vendor.cve_id = cve_identifier.link
+		JOIN cpe_vendor ON cpe_vendor.id = cve_cpe_vendor.vendor_id;`,
+		`CREATE OR REPLACE VIEW vulnerability_product AS
+		SELECT DISTINCT cve_identifier.vulnerability_id, cpe_vendor.name AS vendor, cpe_product.id AS product_id, cpe_product.name AS product
+		FROM cve_identifier
+		JOIN cve_cpe_product ON cve_cpe_product.cve_id = cve_identifier.link
+		JOIN cpe_product ON cpe_product.id = cve_cpe_product.product_id
+		JOIN cpe_vendor ON cpe_vendor.id = cpe_product.vendor_id;`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Функция для получения уязвимых производителей и продуктов CVE из конфигураций CPE, загруженных parser_nvd.
+// Ошибка чтения таблиц NVD не мешает получить производителей из OpenCVE.
+func fetchNVDProducts(ctx context.Context, dbpool *pgxpool.Pool, cveID string, logger *log.Logger) []CPEProduct {
+	rows, err := dbpool.Query(ctx, `
+		SELECT DISTINCT cve_nvd_cpe_match.vendor, COALESCE(cve_nvd_cpe_match.product, '')
+		FROM cve_nvd
+		JOIN cve_nvd_cpe_config ON cve_nvd_cpe_config.cve_nvd_id = cve_nvd.id
+		JOIN cve_nvd_cpe_node ON cve_nvd_cpe_node.config_id = cve_nvd_cpe_config.id
+		JOIN cve_nvd_cpe_match ON cve_nvd_cpe_match.node_id = cve_nvd_cpe_node.id
+		WHERE cve_nvd.cve_id = $1 AND cve_nvd_cpe_match.vulnerable AND cve_nvd_cpe_match.vendor IS NOT NULL
+	`, cveID)
+	if err != nil {
+		logger.Printf("Не удалось получить продукты NVD для %s: %v\n", cveID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var products []CPEProduct
+	for rows.Next() {
+		var product CPEProduct
+		if err := rows.Scan(&product.Vendor, &product.Product); err != nil {
+			logger.Printf("Не удалось прочитать продукт NVD для %s: %v\n", cveID, err)
+			return nil
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Printf("Не удалось получить продукты NVD для %s: %v\n", cveID, err)
+		return nil
+	}
+	return normalizeProducts(products)
+}
+
+// Products возвращает производителей и продукты записи OpenCVE. OpenCVE 2.x возвращает список
+// строк "vendor" и "vendor$PRODUCT$product", OpenCVE 1.x - словарь производителей со списками продуктов.
+func (c *OpenCVECVE) Products() []CPEProduct {
+	if len(c.Vendors) == 0 {
+		return nil
+	}
+
+	var products []CPEProduct
+	var list []string
+	if err := json.Unmarshal(c.Vendors, &list); err == nil {
+		for _, item := range list {
+			vendor, product, _ := strings.Cut(item, openCVEProductSeparator)
+			products = append(products, CPEProduct{Vendor: vendor, Product: product})
+		}
+		return normalizeProducts(products)
+	}
+
+	var byVendor map[string][]string
+	if err := json.Unmarshal(c.Vendors, &byVendor); err == nil {
+		for vendor, names := range byVendor {
+			products = append(products, CPEProduct{Vendor: vendor})
+			for _, product := range names {
+				products = append(products, CPEProduct{Vendor: vendor, Product: product})
+			}
+		}
+	}
+	return normalizeProducts(products)
+}
+
+// Функция для приведения имен к виду CPE (нижний регистр без пробелов по краям),
+// удаления подстановочных значений и повторов
+func normalizeProducts(products []CPEProduct) []CPEProduct {
+	seen := make(map[CPEProduct]bool, len(products))
+	normalized := make([]CPEProduct, 0, len(products))
+	for _, product := range products {
+		product.Vendor = strings.ToLower(strings.TrimSpace(product.Vendor))
+		product.Product = strings.ToLower(strings.TrimSpace(product.Product))
+		if product.Vendor == "" || product.Vendor == "*" || product.Vendor == "-" {
+			continue
+		}
+		if product.Product == "*" || product.Product == "-" {
+			product.Product = ""
+		}
+		if seen[product] {
+			continue
+		}
+		seen[product] = true
+		normalized = append(normalized, product)
+	}
+	return normalized
+}
+
+// Функция для замены связей CVE с производителями и продуктами. Справочники пополняются одним запросом
+// в порядке (производитель, продукт) без перезаписи существующих строк, чтобы параллельные воркеры
+// не получали взаимоблокировок на общих производителях.
+func saveProducts(ctx context.Context, tx pgx.Tx, cveID string, products []CPEProduct, source string) error {
+	_, err := tx.Exec(ctx, `DELETE FROM cve_cpe_vendor WHERE cve_id = $1`, cveID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM cve_cpe_product WHERE cve_id = $1`, cveID)
+	if err != nil {
+		return err
+	}
+
+	products = append([]CPEProduct(nil), products...)
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].Vendor != products[j].Vendor {
+			return products[i].Vendor < products[j].Vendor
+		}
+		return products[i].Product < products[j].Product
+	})
+	var vendors, productVendors, productNames []string
+	for i, product := range products {
+		if i == 0 || product.Vendor != products[i-1].Vendor {
+			vendors = append(vendors, product.Vendor)
+		}
+		if product.Product != "" {
+			productVendors = append(productVendors, product.Vendor)
+			productNames = append(productNames, product.Product)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO cpe_vendor (name)
+		SELECT name FROM unnest($1::text[]) AS name ORDER BY name
+		ON CONFLICT (name) DO NOTHING
+	`, vendors)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO cve_cpe_vendor (cve_id, vendor_id, source)
+		SELECT $1, id, $3 FROM cpe_vendor WHERE name = ANY($2::text[])
+		ON CONFLICT DO NOTHING
+	`, cveID, vendors, source)
+	if err != nil {
+		return err
+	}
+
+	if len(productNames) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO cpe_product (vendor_id, name)
+		SELECT cpe_vendor.id, p.product
+		FROM unnest($1::text[], $2::text[]) AS p(vendor, product)
+		JOIN cpe_vendor ON cpe_vendor.name = p.vendor
+		ORDER BY cpe_vendor.id, p.product
+		ON CONFLICT (vendor_id, name) DO NOTHING
+	`, productVendors, productNames)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO cve_cpe_product (cve_id, product_id, source)
+		SELECT $1, cpe_product.id, $4
+		FROM unnest($2::text[], $3::text[]) AS p(vendor, product)
+		JOIN cpe_vendor ON cpe_vendor.name = p.vendor
+		JOIN cpe_product ON cpe_product.vendor_id = cpe_vendor.id AND cpe_product.name = p.product
+		ON CONFLICT DO NOTHING
+	`, cveID, productVendors, productNames, source)
+	return err
+}
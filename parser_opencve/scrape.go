@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Адрес страниц CVE на opencve.io, которые разбирались до перехода на API
+const openCVEScrapeDefaultURL = "https://www.opencve.io/cve"
+
+// OpenCVEScraper - разбор HTML-страницы CVE на opencve.io. Используется как последний источник
+// метрик, когда ни один вектор CVSS из NVD, БДУ и API OpenCVE не дал оценки.
+type OpenCVEScraper struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Функция для создания разборщика страниц OpenCVE из переменных окружения. Возвращает nil,
+// если разбор страниц не включен в OPENCVE_SCRAPE.
+func newOpenCVEScraperFromEnv() *OpenCVEScraper {
+	if !strings.EqualFold(strings.TrimSpace(os.Getenv("OPENCVE_SCRAPE")), "true") {
+		return nil
+	}
+	baseURL := strings.TrimRight(os.Getenv("OPENCVE_SCRAPE_URL"), "/")
+	if baseURL == "" {
+		baseURL = openCVEScrapeDefaultURL
+	}
+	return &OpenCVEScraper{baseURL: baseURL, httpClient: &http.Client{Timeout: openCVERequestTimeout}}
+}
+
+// Scrape получает базовые метрики CVE со страницы OpenCVE с повторными попытками
+func (s *OpenCVEScraper) Scrape(ctx context.Context, cveID string, logger *log.Logger) (CveData, error) {
+	pageURL := fmt.Sprintf("%s/%s", s.baseURL, url.PathEscape(cveID))
+
+	var lastErr error
+	for i := 0; i < openCVEMaxRetries; i++ {
+		if i > 0 {
+			// Случайная задержка перед повторной попыткой от 1 миллисекунды до 2 секунд
+			delay := time.Duration(rand.Intn(2000-1)+1) * time.Millisecond
+			select {
+			case <-ctx.Done():
+				return CveData{}, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		cveData, retry, err := s.scrapePage(ctx, pageURL)
+		if err == nil {
+			return cveData, nil
+		}
+		if !retry {
+			return CveData{}, err
+		}
+		logger.Printf("Попытка %d: ошибка при получении страницы %s: %v\n", i+1, pageURL, err)
+		lastErr = err
+	}
+	return CveData{}, fmt.Errorf("превышено максимальное количество попыток для URL %s: %w", pageURL, lastErr)
+}
+
+// Функция для получения и разбора одной страницы. Возвращает признак того, что запрос можно повторить.
+func (s *OpenCVEScraper) scrapePage(ctx context.Context, pageURL string) (CveData, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return CveData{}, false, err
+	}
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return CveData{}, true, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return CveData{}, false, errCVENotFound
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return CveData{}, true, fmt.Errorf("получен код ответа %d", res.StatusCode)
+	case res.StatusCode != http.StatusOK:
+		return CveData{}, false, fmt.Errorf("получен ненормативный код ответа %d", res.StatusCode)
+	}
+
+	cveData, err := cveDataFromPage(res.Body)
+	if err != nil {
+		return CveData{}, true, fmt.Errorf("ошибка при парсинге HTML: %w", err)
+	}
+	return cveData, false, nil
+}
+
+// Функция для извлечения базовых метрик CVSS из HTML-страницы CVE OpenCVE: значение метрики
+// выводится в элементе .pull-right заголовка h4 с ее названием. Вектора и оценки на странице нет.
+func cveDataFromPage(r io.Reader) (CveData, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return CveData{}, err
+	}
+	metric := func(name string) string {
+		return strings.TrimSpace(doc.Find(fmt.Sprintf("h4:contains('%s') .pull-right", name)).First().Text())
+	}
+	return CveData{
+		AttackVector:          metric("Attack Vector"),
+		AttackComplexity:      metric("Attack Complexity"),
+		PrivilegesRequired:    metric("Privileges Required"),
+		UserInteraction:       metric("User Interaction"),
+		ConfidentialityImpact: metric("Confidentiality Impact"),
+		IntegrityImpact:       metric("Integrity Impact"),
+		AvailabilityImpact:    metric("Availability Impact"),
+		Scope:                 metric("Scope"),
+		Source:                sourceOpenCVEPage,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var openCVEPageMetrics = CveData{
+	AttackVector:          "Network",
+	AttackComplexity:      "Low",
+	PrivilegesRequired:    "None",
+	UserInteraction:       "None",
+	ConfidentialityImpact: "High",
+	IntegrityImpact:       "High",
+	AvailabilityImpact:    "High",
+	Scope:                 "Changed",
+	Source:                sourceOpenCVEPage,
+}
+
+func TestCveDataFromPage(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "opencve_cve.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := cveDataFromPage(f)
+	if err != nil {
+		t.Fatalf("cveDataFromPage: %v", err)
+	}
+	if got.AttackVector != openCVEPageMetrics.AttackVector || got.UserInteraction != openCVEPageMetrics.UserInteraction ||
+		got.Scope != openCVEPageMetrics.Scope || got.AvailabilityImpact != openCVEPageMetrics.AvailabilityImpact ||
+		got.Source != sourceOpenCVEPage {
+		t.Errorf("cveDataFromPage() = %+v, want %+v", got, openCVEPageMetrics)
+	}
+
+	empty, err := cveDataFromPage(strings.NewReader("<html><body><h4>Описание</h4></body></html>"))
+	if err != nil || !empty.IsEmpty() {
+		t.Errorf("cveDataFromPage() without metrics = %+v, %v, want empty", empty, err)
+	}
+}
+
+func TestOpenCVEScraper(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "opencve_cve.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cve/CVE-2021-44228":
+			w.Write(page)
+		case "/cve/CVE-2021-0001":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	scraper := &OpenCVEScraper{baseURL: server.URL + "/cve", httpClient: server.Client()}
+	logger := log.New(io.Discard, "", 0)
+
+	got, err := scraper.Scrape(context.Background(), "CVE-2021-44228", logger)
+	if err != nil || got.AttackVector != "Network" || got.Scope != "Changed" {
+		t.Errorf("Scrape() = %+v, %v", got, err)
+	}
+	if _, err := scraper.Scrape(context.Background(), "CVE-1999-0001", logger); !errors.Is(err, errCVENotFound) {
+		t.Errorf("Scrape() error = %v, want errCVENotFound", err)
+	}
+	if _, err := scraper.Scrape(context.Background(), "CVE-2021-0001", logger); err == nil {
+		t.Error("Scrape() want error for forbidden page")
+	}
+}
+
+func TestNewOpenCVEScraperFromEnv(t *testing.T) {
+	t.Setenv("OPENCVE_SCRAPE", "")
+	if newOpenCVEScraperFromEnv() != nil {
+		t.Error("scraper enabled without OPENCVE_SCRAPE")
+	}
+	t.Setenv("OPENCVE_SCRAPE", "true")
+	t.Setenv("OPENCVE_SCRAPE_URL", "")
+	if s := newOpenCVEScraperFromEnv(); s == nil || s.baseURL != openCVEScrapeDefaultURL {
+		t.Errorf("newOpenCVEScraperFromEnv() = %+v, want default URL", s)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"parser_opencve/cvss"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Источники векторов CVSS
+const (
+	sourceNVD     = "nvd"
+	sourceBDU     = "bdu"
+	sourceOpenCVE = "opencve"
+	// Метрики со страницы opencve.io без вектора и оценки
+	sourceOpenCVEPage = "opencve_page"
+)
+
+// vectorCandidate - вектор CVSS из NVD, выгрузки БДУ или OpenCVE
+type vectorCandidate struct {
+	Source  string
+	Vector  string
+	Version string   // версия для векторов без префикса (CVSS 3 в БДУ)
+	Score   *float64 // оценка, опубликованная источником
+	Primary bool     // основная оценка NVD, а не оценка CNA
+}
+
+// Функция для получения векторов CVSS одной CVE: оценки NVD и векторы уязвимостей БДУ, в которых она упоминается.
+// Таблицы NVD заполняются parser_nvd, поэтому ошибка их чтения не мешает использовать векторы БДУ.
+func fetchVectorCandidates(ctx context.Context, dbpool *pgxpool.Pool, cveID string, logger *log.Logger) ([]vectorCandidate, error) {
+	var candidates []vectorCandidate
+
+	rows, err := dbpool.Query(ctx, `
+		SELECT cve_nvd_metric.vector_string, cve_nvd_metric.cvss_version, cve_nvd_metric.base_score::float8, cve_nvd_metric.type
+		FROM cve_nvd
+		JOIN cve_nvd_metric ON cve_nvd_metric.cve_nvd_id = cve_nvd.id
+		WHERE cve_nvd.cve_id = $1 AND COALESCE(cve_nvd_metric.vector_string, '') <> ''
+	`, cveID)
+	if err != nil {
+		logger.Printf("Не удалось получить векторы NVD для %s: %v\n", cveID, err)
+	} else {
+		for rows.Next() {
+			var candidate vectorCandidate
+			var metricType string
+			if err := rows.Scan(&candidate.Vector, &candidate.Version, &candidate.Score, &metricType); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			candidate.Source = sourceNVD
+			candidate.Primary = metricType == "Primary"
+			candidates = append(candidates, candidate)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Векторы БДУ относятся к уязвимости целиком и используются, если у CVE нет оценки NVD
+	rows, err = dbpool.Query(ctx, `
+		SELECT vulnerability.cvss_vector, vulnerability.cvss3_vector
+		FROM cve_identifier
+		JOIN vulnerability ON vulnerability.id = cve_identifier.vulnerability_id
+		WHERE cve_identifier.link = $1
+	`, cveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cvss2Vector, cvss3Vector *string
+		if err := rows.Scan(&cvss2Vector, &cvss3Vector); err != nil {
+			return nil, err
+		}
+		if cvss3Vector != nil && strings.TrimSpace(*cvss3Vector) != "" {
+			candidates = append(candidates, vectorCandidate{Source: sourceBDU, Vector: *cvss3Vector, Version: cvss.V30})
+		}
+		if cvss2Vector != nil && strings.TrimSpace(*cvss2Vector) != "" {
+			candidates = append(candidates, vectorCandidate{Source: sourceBDU, Vector: *cvss2Vector, Version: cvss.V2})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortCandidates(candidates)
+	return candidates, nil
+}
+
+// Функция для упорядочивания векторов по приоритету выбора
+func sortCandidates(candidates []vectorCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].rank() < candidates[j].rank()
+	})
+}
+
+// rank задает порядок выбора вектора: сначала CVSS 3.x (основная оценка NVD, оценка CNA, БДУ или OpenCVE),
+// затем CVSS 4.0 и в последнюю очередь CVSS 2. Векторы 3.x предпочитаются 4.0, пока метрики
+// в cve_opencve хранятся в терминах CVSS 3 (Scope и т.д.).
+func (c vectorCandidate) rank() int {
+	switch {
+	case c.Version == cvss.V31 || c.Version == cvss.V30:
+		if c.Source == sourceNVD && c.Primary {
+			return 0
+		}
+		if c.Source == sourceNVD {
+			return 1
+		}
+		return 2
+	case c.Version == cvss.V40:
+		return 3
+	case c.Source == sourceBDU:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// Функция для заполнения метрик из первого корректного вектора. Возвращает false,
+// если ни один вектор не удалось разобрать.
+func cveDataFromVectors(candidates []vectorCandidate, logger *log.Logger) (CveData, bool) {
+	for _, candidate := range candidates {
+		vector, err := cvss.ParseVersion(candidate.Vector, candidate.Version)
+		if err != nil {
+			logger.Printf("Некорректный вектор CVSS из %s: %v\n", candidate.Source, err)
+			continue
+		}
+
+		score, err := vector.BaseScore()
+		if err != nil {
+			if candidate.Score == nil {
+				logger.Printf("Нет оценки для вектора %s из %s: %v\n", vector, candidate.Source, err)
+				continue
+			}
+			score = *candidate.Score
+		}
+
+		metrics := vector.BaseMetrics()
+		return CveData{
+			AttackVector:          metrics.AttackVector,
+			AttackComplexity:      metrics.AttackComplexity,
+			PrivilegesRequired:    metrics.PrivilegesRequired,
+			UserInteraction:       metrics.UserInteraction,
+			ConfidentialityImpact: metrics.ConfidentialityImpact,
+			IntegrityImpact:       metrics.IntegrityImpact,
+			AvailabilityImpact:    metrics.AvailabilityImpact,
+			Scope:                 metrics.Scope,
+			CVSSVersion:           vector.Version,
+			VectorString:          vector.String(),
+			BaseScore:             &score,
+			BaseSeverity:          cvss.Severity(vector.Version, score),
+			Source:                candidate.Source,
+		}, true
+	}
+	return CveData{}, false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"parser_opencve/cvss"
+)
+
+func TestSortCandidates(t *testing.T) {
+	candidates := []vectorCandidate{
+		{Source: sourceOpenCVE, Version: cvss.V2},
+		{Source: sourceBDU, Version: cvss.V2},
+		{Source: sourceNVD, Version: cvss.V40},
+		{Source: sourceBDU, Version: cvss.V30},
+		{Source: sourceNVD, Version: cvss.V31},
+		{Source: sourceNVD, Version: cvss.V31, Primary: true},
+	}
+	sortCandidates(candidates)
+
+	want := []vectorCandidate{
+		{Source: sourceNVD, Version: cvss.V31, Primary: true},
+		{Source: sourceNVD, Version: cvss.V31},
+		{Source: sourceBDU, Version: cvss.V30},
+		{Source: sourceNVD, Version: cvss.V40},
+		{Source: sourceBDU, Version: cvss.V2},
+		{Source: sourceOpenCVE, Version: cvss.V2},
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidates[%d] = %+v, want %+v", i, candidates[i], want[i])
+		}
+	}
+}
+
+func TestCveDataFromVectors(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	providerScore := 8.0
+
+	tests := []struct {
+		name       string
+		candidates []vectorCandidate
+		source     string
+		score      float64
+	}{
+		{
+			name: "invalid vector is skipped",
+			candidates: []vectorCandidate{
+				{Source: sourceNVD, Vector: "CVSS:3.1/AV:X", Version: cvss.V31, Primary: true},
+				{Source: sourceBDU, Vector: "AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", Version: cvss.V30},
+			},
+			source: sourceBDU,
+			score:  9.8,
+		},
+		{
+			name: "v4.0 score is computed from the vector",
+			candidates: []vectorCandidate{
+				{Source: sourceNVD, Vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", Version: cvss.V40, Score: &providerScore},
+			},
+			source: sourceNVD,
+			score:  9.3,
+		},
+		{
+			name: "v4.0 without provider score",
+			candidates: []vectorCandidate{
+				{Source: sourceNVD, Vector: "CVSS:4.0/AV:L/AC:L/AT:N/PR:L/UI:P/VC:N/VI:H/VA:H/SC:N/SI:L/SA:L", Version: cvss.V40},
+				{Source: sourceBDU, Vector: "AV:N/AC:L/Au:N/C:C/I:C/A:C", Version: cvss.V2},
+			},
+			source: sourceNVD,
+			score:  5.2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ok := cveDataFromVectors(tt.candidates, logger)
+			if !ok {
+				t.Fatal("cveDataFromVectors() = false, want true")
+			}
+			if data.Source != tt.source || data.BaseScore == nil || *data.BaseScore != tt.score {
+				t.Errorf("cveDataFromVectors() source = %s, score = %v, want %s, %.1f", data.Source, data.BaseScore, tt.source, tt.score)
+			}
+		})
+	}
+}
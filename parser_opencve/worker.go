@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Количество одновременно обрабатываемых CVE
+const openCVEWorkers = 10
+
+// Функция для получения списка CVE уязвимостей, начиная с CVE новых уязвимостей: без метрик,
+// с пустыми метриками и с метриками, полученными раньше staleBefore. Список читается целиком
+// до начала обработки, чтобы воркеры не работали с пулом при открытом курсоре.
+func fetchWorkList(ctx context.Context, dbpool *pgxpool.Pool, staleBefore time.Time) ([]string, error) {
+	rows, err := dbpool.Query(ctx, `
+		SELECT cve_identifier.link
+		FROM cve_identifier
+		JOIN vulnerability ON vulnerability.id = cve_identifier.vulnerability_id
+		LEFT JOIN cve_opencve ON cve_opencve.cve_id = cve_identifier.link
+		WHERE cve_opencve.id IS NULL
+		OR COALESCE(cve_opencve.attack_vector, '') = ''
+		OR cve_opencve.last_fetched IS NULL
+		OR cve_opencve.last_fetched < $1
+		GROUP BY cve_identifier.link
+		ORDER BY max(vulnerability.identifier) DESC`, staleBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cveIDs []string
+	for rows.Next() {
+		var cveID string
+		if err := rows.Scan(&cveID); err != nil {
+			return nil, err
+		}
+		cveIDs = append(cveIDs, cveID)
+	}
+	return cveIDs, rows.Err()
+}
+
+// workResult - итог обработки списка CVE. Skipped - CVE, не обработанные из-за отмены контекста.
+type workResult struct {
+	Processed int64
+	Failed    int64
+	Skipped   int64
+}
+
+// cveFetcher получает метрики CVE, cveSaver сохраняет их
+type (
+	cveFetcher func(ctx context.Context, cveID string) (CveData, error)
+	cveSaver   func(ctx context.Context, cveID string, cveData CveData) error
+)
+
+// Функция для обработки списка CVE пулом из workers воркеров. Ошибка fetch или save относится только
+// к одной CVE и учитывается в итоге, не прерывая обработку остальных. При отмене ctx новые CVE
+// не передаются воркерам и вместе с прерванными учитываются как пропущенные.
+func runWorkers(ctx context.Context, cveIDs []string, workers int, fetch cveFetcher, save cveSaver) workResult {
+	var result workResult
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cveID := range jobs {
+				cveData, err := fetch(ctx, cveID)
+				if err == nil {
+					err = save(ctx, cveID, cveData)
+				}
+				switch {
+				case err == nil:
+					atomic.AddInt64(&result.Processed, 1)
+				case ctx.Err() != nil:
+					atomic.AddInt64(&result.Skipped, 1)
+				default:
+					atomic.AddInt64(&result.Failed, 1)
+				}
+			}
+		}()
+	}
+
+	queued := 0
+queue:
+	for _, cveID := range cveIDs {
+		select {
+		case jobs <- cveID:
+			queued++
+		case <-ctx.Done():
+			break queue
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	result.Skipped += int64(len(cveIDs) - queued)
+	return result
+}
+
+// cveStore получает метрики CVE из БД и OpenCVE и сохраняет их, записывая неудачные попытки в cve_opencve_failure
+type cveStore struct {
+	dbpool  *pgxpool.Pool
+	openCVE *OpenCVEClient
+	scraper *OpenCVEScraper
+	logger  *log.Logger
+}
+
+// Fetch получает метрики одной CVE
+func (s *cveStore) Fetch(ctx context.Context, cveID string) (CveData, error) {
+	cveData, err := fetchCveData(ctx, s.dbpool, s.openCVE, s.scraper, cveID, s.logger)
+	if err != nil {
+		s.fail(ctx, cveID, err)
+	}
+	return cveData, err
+}
+
+// Save сохраняет метрики одной CVE и удаляет запись о неудачных попытках
+func (s *cveStore) Save(ctx context.Context, cveID string, cveData CveData) error {
+	// Сохранение данных в таблицу cve_opencve
+	if err := saveCveData(ctx, s.dbpool, cveID, cveData); err != nil {
+		s.fail(ctx, cveID, err)
+		return err
+	}
+	if err := clearFailure(ctx, s.dbpool, cveID); err != nil {
+		s.logger.Printf("Ошибка при удалении записи о неудачных попытках для %s: %v\n", cveID, err)
+	}
+	s.logger.Printf("Данные для %s успешно сохранены (%s)\n", cveID, cveData.Source)
+	return nil
+}
+
+// Функция для записи неудачной попытки; прерванная отменой контекста попытка не записывается
+func (s *cveStore) fail(ctx context.Context, cveID string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	s.logger.Printf("Не удалось получить метрики для %s: %v\n", cveID, err)
+	if recordErr := recordFailure(ctx, s.dbpool, cveID, err); recordErr != nil {
+		s.logger.Printf("Ошибка при записи неудачной попытки для %s: %v\n", cveID, recordErr)
+	}
+}
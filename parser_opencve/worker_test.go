@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func testCVEIDs(n int) []string {
+	cveIDs := make([]string, n)
+	for i := range cveIDs {
+		cveIDs[i] = fmt.Sprintf("CVE-2024-%04d", i+1)
+	}
+	return cveIDs
+}
+
+func TestRunWorkers(t *testing.T) {
+	cveIDs := testCVEIDs(100)
+	fetchFails := map[string]bool{}
+	saveFails := map[string]bool{}
+	for i, cveID := range cveIDs {
+		switch {
+		case i%10 == 0:
+			fetchFails[cveID] = true
+		case i%7 == 0:
+			saveFails[cveID] = true
+		}
+	}
+
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	saved := map[string]bool{}
+	fetch := func(ctx context.Context, cveID string) (CveData, error) {
+		mu.Lock()
+		fetched[cveID]++
+		mu.Unlock()
+		if fetchFails[cveID] {
+			return CveData{}, &EmptyCveDataError{CVEID: cveID}
+		}
+		return CveData{VectorString: cveID}, nil
+	}
+	save := func(ctx context.Context, cveID string, cveData CveData) error {
+		if cveData.VectorString != cveID {
+			t.Errorf("save(%s) получил данные %s", cveID, cveData.VectorString)
+		}
+		if saveFails[cveID] {
+			return errors.New("ошибка сохранения")
+		}
+		mu.Lock()
+		saved[cveID] = true
+		mu.Unlock()
+		return nil
+	}
+
+	result := runWorkers(context.Background(), cveIDs, 8, fetch, save)
+
+	want := workResult{
+		Processed: int64(len(cveIDs) - len(fetchFails) - len(saveFails)),
+		Failed:    int64(len(fetchFails) + len(saveFails)),
+	}
+	if result != want {
+		t.Errorf("runWorkers() = %+v, want %+v", result, want)
+	}
+	for _, cveID := range cveIDs {
+		if fetched[cveID] != 1 {
+			t.Errorf("%s получена %d раз, want 1", cveID, fetched[cveID])
+		}
+	}
+	if int64(len(saved)) != want.Processed {
+		t.Errorf("сохранено %d CVE, want %d", len(saved), want.Processed)
+	}
+}
+
+func TestRunWorkersCancelled(t *testing.T) {
+	cveIDs := testCVEIDs(200)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int64
+	fetch := func(ctx context.Context, cveID string) (CveData, error) {
+		if atomic.AddInt64(&calls, 1) == 20 {
+			cancel()
+		}
+		if err := ctx.Err(); err != nil {
+			return CveData{}, err
+		}
+		return CveData{}, nil
+	}
+	save := func(ctx context.Context, cveID string, cveData CveData) error {
+		return ctx.Err()
+	}
+
+	result := runWorkers(ctx, cveIDs, 4, fetch, save)
+
+	if result.Processed+result.Failed+result.Skipped != int64(len(cveIDs)) {
+		t.Errorf("runWorkers() = %+v, сумма не равна %d", result, len(cveIDs))
+	}
+	if result.Failed != 0 {
+		t.Errorf("runWorkers() Failed = %d, want 0", result.Failed)
+	}
+	if result.Processed >= 20 || result.Skipped <= int64(len(cveIDs))-20 {
+		t.Errorf("runWorkers() = %+v, после отмены обработка должна остановиться", result)
+	}
+}
+
+func TestRunWorkersCancelledBeforeStart(t *testing.T) {
+	cveIDs := testCVEIDs(50)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fetch := func(ctx context.Context, cveID string) (CveData, error) {
+		return CveData{}, ctx.Err()
+	}
+	save := func(ctx context.Context, cveID string, cveData CveData) error {
+		t.Errorf("save(%s) вызван после отмены", cveID)
+		return nil
+	}
+
+	result := runWorkers(ctx, cveIDs, 4, fetch, save)
+
+	want := workResult{Skipped: int64(len(cveIDs))}
+	if result != want {
+		t.Errorf("runWorkers() = %+v, want %+v", result, want)
+	}
+}
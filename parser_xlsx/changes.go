@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"vkr_common/textnorm"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Виды изменений перечня угроз, записываемые в ubi_changes
+const (
+	changeAdded    = "added"
+	changeModified = "modified"
+	changeRemoved  = "removed"
+	changeRestored = "restored"
+)
+
+// threatState - состояние угрозы в базе данных до начала импорта
+type threatState struct {
+	Threat
+	Removed bool
+}
+
+// threatChange - изменение угрозы между двумя импортами
+type threatChange struct {
+	ThreatID      int
+	Type          string
+	ChangedFields []string
+	OldData       map[string]string
+	NewData       map[string]string
+}
+
+// Функция для получения значений угрозы по именам столбцов таблицы ubi
+func (t Threat) columns() map[string]string {
+	return map[string]string{
+		"name":                      t.Name,
+		"description":               t.Description,
+		"source":                    t.Source,
+		"object":                    t.Object,
+		"confidentiality_violation": t.ConfidentialityViolation,
+		"integrity_violation":       t.IntegrityViolation,
+		"availability_violation":    t.AvailabilityViolation,
+	}
+}
+
+// Функция для загрузки угроз из базы данных, индексированных по номеру УБИ
+func loadThreatStates(ctx context.Context, pool *pgxpool.Pool) (map[int]threatState, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT threat_id, COALESCE(name, ''), COALESCE(description, ''), COALESCE(source, ''), COALESCE(object, ''),
+			COALESCE(confidentiality_violation, ''), COALESCE(integrity_violation, ''), COALESCE(availability_violation, ''), removed
+		FROM ubi
+		WHERE threat_id IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[int]threatState)
+	for rows.Next() {
+		var state threatState
+		err := rows.Scan(&state.ThreatID, &state.Name, &state.Description, &state.Source, &state.Object,
+			&state.ConfidentialityViolation, &state.IntegrityViolation, &state.AvailabilityViolation, &state.Removed)
+		if err != nil {
+			return nil, err
+		}
+		states[state.ThreatID] = state
+	}
+	return states, rows.Err()
+}
+
+// Функция для загрузки угроз, сохраненных прежними версиями парсера без номера УБИ: нормализованное
+// наименование -> id самой новой записи с этим наименованием
+func loadLegacyThreats(ctx context.Context, pool *pgxpool.Pool) (map[string]int, error) {
+	rows, err := pool.Query(ctx, `SELECT id, COALESCE(name, '') FROM ubi WHERE threat_id IS NULL AND NOT removed ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	legacy := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		legacy[textnorm.Normalize(name)] = id
+	}
+	return legacy, rows.Err()
+}
+
+// Функция для сравнения угрозы из перечня с ее состоянием до импорта
+func detectThreatChange(previous map[int]threatState, threat Threat) (threatChange, bool) {
+	state, ok := previous[threat.ThreatID]
+	if !ok {
+		return threatChange{ThreatID: threat.ThreatID, Type: changeAdded, NewData: threat.columns()}, true
+	}
+
+	oldData, newData := state.Threat.columns(), threat.columns()
+	var changedFields []string
+	for column, value := range newData {
+		if oldData[column] != value {
+			changedFields = append(changedFields, column)
+		}
+	}
+	sort.Strings(changedFields)
+
+	change := threatChange{ThreatID: threat.ThreatID, ChangedFields: changedFields, OldData: oldData, NewData: newData}
+	switch {
+	case state.Removed:
+		change.Type = changeRestored
+	case len(changedFields) > 0:
+		change.Type = changeModified
+	default:
+		return threatChange{}, false
+	}
+	return change, true
+}
+
+// Функция для добавления в пакет записи об изменении угрозы
+func queueThreatChange(batch *pgx.Batch, change threatChange) {
+	batch.Queue(`
+		INSERT INTO ubi_changes (threat_id, change_type, changed_fields, old_data, new_data)
+		VALUES ($1, $2, $3, $4, $5)`,
+		change.ThreatID, change.Type, change.ChangedFields, change.OldData, change.NewData)
+}
+
+// Функция для отметки угроз, исключенных из перечня, как удаленных
+func markRemovedThreats(ctx context.Context, pool *pgxpool.Pool, previous map[int]threatState, seen map[int]bool) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	removed := 0
+	for threatID, state := range previous {
+		if seen[threatID] || state.Removed {
+			continue
+		}
+		_, err := tx.Exec(ctx, `UPDATE ubi SET removed = TRUE, removed_at = now() WHERE threat_id = $1`, threatID)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO ubi_changes (threat_id, change_type, old_data)
+			VALUES ($1, $2, $3)`,
+			threatID, changeRemoved, state.Threat.columns())
+		if err != nil {
+			return 0, err
+		}
+		removed++
+	}
+
+	// Записи без номера, не сопоставленные ни с одной угрозой перечня, - устаревшие копии
+	// из прежних версий парсера, их удаление в ubi_changes не записывается
+	_, err = tx.Exec(ctx, `UPDATE ubi SET removed = TRUE, removed_at = now() WHERE threat_id IS NULL AND NOT removed`)
+	if err != nil {
+		return 0, err
+	}
+	return removed, tx.Commit(ctx)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Правила связывания по умолчанию: идентификатор CWE -> номера УБИ.
+// Перечень дополняется без пересборки через таблицу ubi_link_rule.
+var defaultCWERules = map[string][]int{
+	"CWE-22":  {15},
+	"CWE-23":  {15},
+	"CWE-59":  {15},
+	"CWE-77":  {6},
+	"CWE-78":  {6},
+	"CWE-79":  {6},
+	"CWE-89":  {6},
+	"CWE-94":  {6},
+	"CWE-434": {6},
+	"CWE-502": {6},
+	"CWE-917": {6},
+	"CWE-119": {93},
+	"CWE-120": {93},
+	"CWE-121": {93},
+	"CWE-122": {93},
+	"CWE-125": {93},
+	"CWE-415": {93},
+	"CWE-416": {93},
+	"CWE-787": {93},
+	"CWE-400": {140},
+	"CWE-401": {140},
+	"CWE-476": {140},
+	"CWE-674": {140},
+	"CWE-770": {140},
+	"CWE-835": {140},
+	"CWE-250": {122},
+	"CWE-264": {122},
+	"CWE-269": {122},
+	"CWE-284": {31},
+	"CWE-285": {31},
+	"CWE-639": {31},
+	"CWE-862": {31},
+	"CWE-863": {31},
+	"CWE-200": {67},
+	"CWE-209": {67},
+	"CWE-359": {67},
+	"CWE-532": {67},
+	"CWE-256": {74},
+	"CWE-287": {74},
+	"CWE-312": {74},
+	"CWE-319": {74},
+	"CWE-522": {74},
+	"CWE-798": {30},
+	"CWE-345": {145},
+	"CWE-347": {145},
+	"CWE-494": {145},
+}
+
+// Соответствие типа ПО из БДУ (фрагмент в нижнем регистре) фрагментам поля object угроз
+var objectKeywords = map[string][]string{
+	"операционная система": {"системное программное обеспечение", "операционн"},
+	"прикладное":           {"прикладное программное обеспечение", "информационная система"},
+	"субд":                 {"база данных", "баз данных", "системное программное обеспечение"},
+	"сетев":                {"сетевое программное обеспечение", "сетевой узел", "сетевое оборудование"},
+	"виртуал":              {"гипервизор", "виртуальн"},
+	"микропрограмм":        {"микропрограммное обеспечение", "аппаратное обеспечение"},
+	"программно-аппаратн":  {"микропрограммное обеспечение", "аппаратное обеспечение", "системное программное обеспечение"},
+	"средство защиты":      {"средство защиты", "системное программное обеспечение"},
+}
+
+// Причины появления связи уязвимости с угрозой
+const (
+	linkReasonRule   = "rule"
+	linkReasonObject = "rule+object"
+	linkReasonManual = "manual"
+)
+
+type threatRef struct {
+	ID     int
+	Object string
+}
+
+type vulnerabilityFacts struct {
+	ID            int
+	Identifier    string
+	VulClass      string
+	CWEs          []string
+	SoftwareTypes []string
+}
+
+type threatLink struct {
+	VulnerabilityID int
+	UbiID           int
+	Reason          string
+}
+
+// Функция для создания таблиц правил и связей уязвимостей с угрозами
+func createLinkTables(ctx context.Context, pool *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS ubi_link_rule (
+			kind TEXT NOT NULL CHECK (kind IN ('cwe', 'vul_class')),
+			value TEXT NOT NULL,
+			threat_id INTEGER NOT NULL,
+			PRIMARY KEY(kind, value, threat_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS ubi_link_override (
+			vulnerability_identifier TEXT NOT NULL,
+			threat_id INTEGER NOT NULL,
+			excluded BOOLEAN NOT NULL DEFAULT FALSE,
+			PRIMARY KEY(vulnerability_identifier, threat_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS vulnerability_ubi (
+			vulnerability_id INTEGER NOT NULL,
+			ubi_id INTEGER NOT NULL,
+			reason TEXT,
+			PRIMARY KEY(vulnerability_id, ubi_id),
+			FOREIGN KEY(vulnerability_id) REFERENCES vulnerability(id) ON DELETE CASCADE,
+			FOREIGN KEY(ubi_id) REFERENCES ubi(id) ON DELETE CASCADE
+		);`,
+	}
+	for _, query := range queries {
+		if _, err := pool.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	// Заполнение правил по умолчанию, правила из базы данных не перезаписываются
+	for cwe, threatIDs := range defaultCWERules {
+		for _, threatID := range threatIDs {
+			_, err := pool.Exec(ctx, `
+				INSERT INTO ubi_link_rule (kind, value, threat_id)
+				VALUES ('cwe', $1, $2)
+				ON CONFLICT DO NOTHING;`, cwe, threatID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Функция для связывания уязвимостей БДУ с угрозами УБИ по CWE, классу уязвимости и объектам воздействия
+func linkVulnerabilitiesToThreats(ctx context.Context, pool *pgxpool.Pool, log *log.Logger) error {
+	if err := createLinkTables(ctx, pool); err != nil {
+		return fmt.Errorf("создание таблиц связей: %w", err)
+	}
+
+	threats, err := loadThreatRefs(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("загрузка угроз: %w", err)
+	}
+	cweRules, classRules, err := loadLinkRules(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("загрузка правил: %w", err)
+	}
+	overrides, err := loadLinkOverrides(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("загрузка ручных связей: %w", err)
+	}
+	vulnerabilities, err := loadVulnerabilityFacts(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("загрузка уязвимостей: %w", err)
+	}
+
+	var links []threatLink
+	for _, vul := range vulnerabilities {
+		links = append(links, matchThreats(vul, threats, cweRules, classRules, overrides[vul.Identifier])...)
+	}
+
+	// Связи пересчитываются целиком в одной транзакции
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM vulnerability_ubi`); err != nil {
+		return err
+	}
+	rows := make([][]interface{}, 0, len(links))
+	for _, link := range links {
+		rows = append(rows, []interface{}{link.VulnerabilityID, link.UbiID, link.Reason})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"vulnerability_ubi"},
+		[]string{"vulnerability_id", "ubi_id", "reason"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("Связано уязвимостей с угрозами: %d связей для %d уязвимостей\n", len(links), len(vulnerabilities))
+	return nil
+}
+
+// Функция для выбора угроз, которые реализует уязвимость
+func matchThreats(vul vulnerabilityFacts, threats map[int]threatRef, cweRules, classRules map[string][]int, overrides map[int]bool) []threatLink {
+	candidates := make(map[int]bool)
+	for _, cwe := range vul.CWEs {
+		for _, threatID := range cweRules[cwe] {
+			candidates[threatID] = true
+		}
+	}
+	// Класс уязвимости используется, только если по CWE ничего не найдено
+	if len(candidates) == 0 {
+		for _, threatID := range classRules[strings.ToLower(strings.TrimSpace(vul.VulClass))] {
+			candidates[threatID] = true
+		}
+	}
+
+	// Отбор по объектам воздействия: если у уязвимости известны типы ПО, оставляются угрозы
+	// с подходящим объектом. Если подходящих нет, сохраняются все кандидаты.
+	keywords := objectKeywordsFor(vul.SoftwareTypes)
+	matched := make(map[int]bool)
+	for threatID := range candidates {
+		if threat, ok := threats[threatID]; ok && objectMatches(threat.Object, keywords) {
+			matched[threatID] = true
+		}
+	}
+
+	reasons := make(map[int]string)
+	for threatID := range candidates {
+		if len(matched) == 0 {
+			reasons[threatID] = linkReasonRule
+		} else if matched[threatID] {
+			reasons[threatID] = linkReasonObject
+		}
+	}
+	for threatID, excluded := range overrides {
+		if excluded {
+			delete(reasons, threatID)
+		} else {
+			reasons[threatID] = linkReasonManual
+		}
+	}
+
+	var links []threatLink
+	for threatID, reason := range reasons {
+		threat, ok := threats[threatID]
+		if !ok {
+			continue
+		}
+		links = append(links, threatLink{VulnerabilityID: vul.ID, UbiID: threat.ID, Reason: reason})
+	}
+	return links
+}
+
+// Функция для получения фрагментов объектов воздействия по типам ПО уязвимости
+func objectKeywordsFor(softwareTypes []string) []string {
+	var keywords []string
+	for _, softwareType := range softwareTypes {
+		softwareType = strings.ToLower(softwareType)
+		for fragment, objects := range objectKeywords {
+			if strings.Contains(softwareType, fragment) {
+				keywords = append(keywords, objects...)
+			}
+		}
+	}
+	return keywords
+}
+
+// Функция для проверки соответствия объекта воздействия угрозы типам ПО
+func objectMatches(object string, keywords []string) bool {
+	object = strings.ToLower(object)
+	for _, keyword := range keywords {
+		if strings.Contains(object, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Функция для загрузки действующих угроз, индексированных по номеру УБИ
+func loadThreatRefs(ctx context.Context, pool *pgxpool.Pool) (map[int]threatRef, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, threat_id, COALESCE(object, '')
+		FROM ubi
+		WHERE threat_id IS NOT NULL AND NOT removed`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threats := make(map[int]threatRef)
+	for rows.Next() {
+		var ref threatRef
+		var threatID int
+		if err := rows.Scan(&ref.ID, &threatID, &ref.Object); err != nil {
+			return nil, err
+		}
+		threats[threatID] = ref
+	}
+	return threats, rows.Err()
+}
+
+// Функция для загрузки правил связывания по CWE и по классу уязвимости
+func loadLinkRules(ctx context.Context, pool *pgxpool.Pool) (map[string][]int, map[string][]int, error) {
+	rows, err := pool.Query(ctx, `SELECT kind, value, threat_id FROM ubi_link_rule`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	cweRules := make(map[string][]int)
+	classRules := make(map[string][]int)
+	for rows.Next() {
+		var kind, value string
+		var threatID int
+		if err := rows.Scan(&kind, &value, &threatID); err != nil {
+			return nil, nil, err
+		}
+		switch kind {
+		case "cwe":
+			value = strings.ToUpper(strings.TrimSpace(value))
+			cweRules[value] = append(cweRules[value], threatID)
+		case "vul_class":
+			value = strings.ToLower(strings.TrimSpace(value))
+			classRules[value] = append(classRules[value], threatID)
+		}
+	}
+	return cweRules, classRules, rows.Err()
+}
+
+// Функция для загрузки ручных связей: идентификатор BDU -> номер УБИ -> исключена ли связь
+func loadLinkOverrides(ctx context.Context, pool *pgxpool.Pool) (map[string]map[int]bool, error) {
+	rows, err := pool.Query(ctx, `SELECT vulnerability_identifier, threat_id, excluded FROM ubi_link_override`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]map[int]bool)
+	for rows.Next() {
+		var identifier string
+		var threatID int
+		var excluded bool
+		if err := rows.Scan(&identifier, &threatID, &excluded); err != nil {
+			return nil, err
+		}
+		if overrides[identifier] == nil {
+			overrides[identifier] = make(map[int]bool)
+		}
+		overrides[identifier][threatID] = excluded
+	}
+	return overrides, rows.Err()
+}
+
+// Функция для загрузки уязвимостей вместе с их CWE и типами ПО
+func loadVulnerabilityFacts(ctx context.Context, pool *pgxpool.Pool) ([]vulnerabilityFacts, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT v.id, v.identifier, COALESCE(v.vul_class, ''),
+			COALESCE((SELECT array_agg(DISTINCT c.identifier) FROM cwe_identifier c WHERE c.vulnerability_id = v.id), '{}'),
+			COALESCE((SELECT array_agg(DISTINCT s.type) FROM software s WHERE s.vulnerability_id = v.id AND s.type IS NOT NULL), '{}')
+		FROM vulnerability v`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vulnerabilities []vulnerabilityFacts
+	for rows.Next() {
+		var vul vulnerabilityFacts
+		if err := rows.Scan(&vul.ID, &vul.Identifier, &vul.VulClass, &vul.CWEs, &vul.SoftwareTypes); err != nil {
+			return nil, err
+		}
+		vulnerabilities = append(vulnerabilities, vul)
+	}
+	return vulnerabilities, rows.Err()
+}
@@ -4,19 +4,35 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strings"
 	"time"
 
+	"vkr_common/textnorm"
+
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
-	"github.com/xuri/excelize/v2"
 )
 
+// Адреса перечня угроз по умолчанию, переопределяются переменной THRLIST_URLS (через запятую).
+// Формат файла определяется по расширению: .xlsx, .xml или .csv
+var defaultThreatListURLs = []string{
+	"https://bdu.fstec.ru/files/documents/thrlist.xlsx",
+}
+
+// Количество угроз, вставляемых в одной транзакции
+const threatBatchSize = 100
+
 type Threat struct {
+	ThreatID                 int
 	Name                     string
 	Description              string
 	Source                   string
@@ -85,25 +101,34 @@ func main() {
 	client := &http.Client{Transport: tr}
 	logger.Println("HTTP клиент настроен")
 
-	// Загрузка XLSX файла
-	xlsxURL := "https://bdu.fstec.ru/files/documents/thrlist.xlsx"
-	xlsxPath := "thrlist.xlsx"
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		err = downloadFile(client, xlsxURL, xlsxPath, logger)
+	// Загрузка перечня угроз: thrlist.xlsx или перечень в другом формате с зеркала
+	threatListURLs := defaultThreatListURLs
+	if urls := os.Getenv("THRLIST_URLS"); urls != "" {
+		threatListURLs = strings.Split(urls, ",")
+	}
+
+	var threatListPath string
+	for _, threatListURL := range threatListURLs {
+		threatListURL = strings.TrimSpace(threatListURL)
+		threatListPath, err = threatListFileName(threatListURL)
+		if err != nil {
+			logger.Printf("Некорректный адрес перечня угроз %s: %v\n", threatListURL, err)
+			continue
+		}
+		err = downloadFile(client, threatListURL, threatListPath, logger)
 		if err == nil {
 			break
 		}
-		logger.Printf("Ошибка при загрузке файла (попытка %d/%d): %v\n", i+1, maxRetries, err)
-		time.Sleep(2 * time.Second)
+		logger.Printf("Не удалось загрузить %s: %v\n", threatListURL, err)
+		os.Remove(threatListPath)
 	}
 	if err != nil {
-		logger.Println("Не удалось загрузить файл после нескольких попыток:", err)
+		logger.Println("Не удалось загрузить перечень угроз ни из одного источника:", err)
 		return
 	}
 
-	// Открытие загруженного XLSX файла
-	f, err := excelize.OpenFile(xlsxPath)
+	// Открытие загруженного перечня угроз
+	source, err := openThreatSource(threatListPath)
 	if err != nil {
 		logger.Fatalf("Ошибка при открытии файла: %s", err)
 	}
@@ -118,19 +143,43 @@ func main() {
 	// Создание таблицы в базе данных
 	createTable(pool, logger)
 
-	// Вставка данных из Excel файла в базу данных
-	insertDataFromExcel(f, pool, logger)
+	// Вставка угроз в базу данных
+	insertThreats(source, pool, logger)
 
-	// Удаление загруженного XLSX файла
-	err = os.Remove(xlsxPath)
+	// Связывание уязвимостей БДУ с угрозами УБИ
+	err = linkVulnerabilitiesToThreats(context.Background(), pool, logger)
 	if err != nil {
-		logger.Println("Ошибка при удалении XLSX файла:", err)
+		logger.Println("Ошибка при связывании уязвимостей с угрозами:", err)
+	}
+
+	// Закрытие и удаление загруженного файла
+	err = source.Close()
+	if err != nil {
+		logger.Println("Ошибка при закрытии файла перечня угроз:", err)
+	}
+	err = os.Remove(threatListPath)
+	if err != nil {
+		logger.Println("Ошибка при удалении файла перечня угроз:", err)
 		return
 	}
 
 	logger.Println("Данные успешно вставлены, файл удален")
 }
 
+// Функция для получения имени локального файла по адресу перечня угроз. Имя берется из пути без
+// параметров запроса, чтобы формат определялся по расширению и для адресов вида thrlist.xlsx?download=1
+func threatListFileName(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return "", fmt.Errorf("в адресе не указано имя файла")
+	}
+	return name, nil
+}
+
 // Функция для загрузки файла с указанного URL
 func downloadFile(client *http.Client, url string, filepath string, log *log.Logger) error {
 	const maxRetries = 5
@@ -186,11 +235,24 @@ func attemptDownload(client *http.Client, url string, filepath string, log *log.
 	return nil
 }
 
+// Функция для нормализации текста всех полей угрозы
+func (t Threat) normalized() Threat {
+	t.Name = textnorm.Normalize(t.Name)
+	t.Description = textnorm.Normalize(t.Description)
+	t.Source = textnorm.Normalize(t.Source)
+	t.Object = textnorm.Normalize(t.Object)
+	t.ConfidentialityViolation = textnorm.Normalize(t.ConfidentialityViolation)
+	t.IntegrityViolation = textnorm.Normalize(t.IntegrityViolation)
+	t.AvailabilityViolation = textnorm.Normalize(t.AvailabilityViolation)
+	return t
+}
+
 // Функция для создания таблицы в базе данных
 func createTable(pool *pgxpool.Pool, log *log.Logger) {
 	createTableQuery := `
     CREATE TABLE IF NOT EXISTS ubi (
         id SERIAL PRIMARY KEY,
+        threat_id INTEGER,
         name TEXT,
         description TEXT,
         source TEXT,
@@ -198,44 +260,203 @@ func createTable(pool *pgxpool.Pool, log *log.Logger) {
         confidentiality_violation TEXT,
         integrity_violation TEXT,
         availability_violation TEXT,
-        UNIQUE(name, description, source, object, confidentiality_violation, integrity_violation, availability_violation)
+        removed BOOLEAN NOT NULL DEFAULT FALSE,
+        removed_at TIMESTAMP,
+        updated_at TIMESTAMP
     );`
 	_, err := pool.Exec(context.Background(), createTableQuery)
 	if err != nil {
 		log.Fatalf("Ошибка при создании таблицы: %v", err)
 	}
+
+	// Приведение таблицы, созданной прежними версиями парсера, к текущей схеме: номер угрозы
+	// (УБИ.001 и т.д.) становится ключом. Записи без номера сопоставляются с угрозами перечня
+	// по наименованию при первом импорте (loadLegacyThreats).
+	migrations := []string{
+		`ALTER TABLE ubi
+            ADD COLUMN IF NOT EXISTS threat_id INTEGER,
+            ADD COLUMN IF NOT EXISTS removed BOOLEAN NOT NULL DEFAULT FALSE,
+            ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP,
+            ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;`,
+		`DO $$
+        DECLARE constraint_name TEXT;
+        BEGIN
+            FOR constraint_name IN
+                SELECT conname FROM pg_constraint
+                WHERE conrelid = 'ubi'::regclass AND contype = 'u' AND array_length(conkey, 1) > 1
+            LOOP
+                EXECUTE format('ALTER TABLE ubi DROP CONSTRAINT %I', constraint_name);
+            END LOOP;
+        END $$;`,
+		`UPDATE ubi SET threat_id = NULL, removed = TRUE, removed_at = COALESCE(ubi.removed_at, now())
+        FROM ubi newer
+        WHERE ubi.threat_id = newer.threat_id AND ubi.id < newer.id;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS ubi_threat_id_key ON ubi(threat_id);`,
+		`CREATE TABLE IF NOT EXISTS ubi_changes (
+            id SERIAL PRIMARY KEY,
+            threat_id INTEGER NOT NULL,
+            change_type TEXT NOT NULL CHECK (change_type IN ('added', 'modified', 'removed', 'restored')),
+            changed_fields TEXT[],
+            old_data JSONB,
+            new_data JSONB,
+            changed_at TIMESTAMP NOT NULL DEFAULT now()
+        );`,
+	}
+	for _, query := range migrations {
+		_, err = pool.Exec(context.Background(), query)
+		if err != nil {
+			log.Fatalf("Ошибка при обновлении схемы таблицы ubi: %v", err)
+		}
+	}
 }
 
-// Функция для вставки данных из Excel файла в базу данных
-func insertDataFromExcel(f *excelize.File, pool *pgxpool.Pool, log *log.Logger) {
-	rows, err := f.GetRows("Sheet")
+// Функция для вставки угроз из источника в базу данных пакетами по threatBatchSize строк.
+// Каждая угроза сравнивается с состоянием до импорта, изменения записываются в ubi_changes.
+func insertThreats(source ThreatSource, pool *pgxpool.Pool, log *log.Logger) {
+	ctx := context.Background()
+	previous, err := loadThreatStates(ctx, pool)
+	if err != nil {
+		log.Fatalf("Ошибка при загрузке текущего перечня угроз: %v", err)
+	}
+	legacy, err := loadLegacyThreats(ctx, pool)
 	if err != nil {
-		log.Fatalf("Ошибка при получении строк: %s", err)
+		log.Fatalf("Ошибка при загрузке угроз без номера: %v", err)
+	}
+	if len(legacy) > 0 {
+		log.Printf("Угроз без номера УБИ: %d, они сопоставляются с перечнем по наименованию, изменения не записываются", len(legacy))
 	}
 
-	ctx := context.Background()
-	for _, row := range rows[2:] { // Пропускаем заголовок
-		if len(row) < 7 {
+	seen := make(map[int]bool)
+	batch := make([]Threat, 0, threatBatchSize)
+	total, failed := 0, 0
+
+	// Пакет с ошибкой повторяется по одной угрозе, чтобы одна некорректная строка
+	// не отменяла вставку остальных угроз пакета
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := insertThreatBatch(ctx, pool, batch, previous, legacy)
+		if err == nil {
+			total += len(batch)
+			batch = batch[:0]
+			return
+		}
+		log.Printf("Ошибка при вставке пакета из %d угроз, повтор по одной: %v", len(batch), err)
+		for _, threat := range batch {
+			if err := insertThreatBatch(ctx, pool, []Threat{threat}, previous, legacy); err != nil {
+				log.Printf("Ошибка при вставке угрозы УБИ.%03d: %v", threat.ThreatID, err)
+				failed++
+				continue
+			}
+			total++
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		threat, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		var skipped *skippedRowError
+		if errors.As(err, &skipped) {
+			log.Println(skipped)
 			continue
 		}
+		if err != nil {
+			log.Fatalf("Ошибка при чтении перечня угроз: %s", err)
+		}
+		threat = threat.normalized()
+		if seen[threat.ThreatID] {
+			log.Printf("Повторная угроза УБИ.%03d в перечне, пропускаем", threat.ThreatID)
+			continue
+		}
+		seen[threat.ThreatID] = true
 
-		threat := Threat{
-			Name:                     row[1],
-			Description:              row[2],
-			Source:                   row[3],
-			Object:                   row[4],
-			ConfidentialityViolation: row[5],
-			IntegrityViolation:       row[6],
-			AvailabilityViolation:    row[7],
+		batch = append(batch, threat)
+		if len(batch) == threatBatchSize {
+			flush()
 		}
+	}
+	flush()
 
-		_, err := pool.Exec(ctx, `
-            INSERT INTO ubi (name, description, source, object, confidentiality_violation, integrity_violation, availability_violation)
-            VALUES ($1, $2, $3, $4, $5, $6, $7)
-            ON CONFLICT (name, description, source, object, confidentiality_violation, integrity_violation, availability_violation) DO NOTHING;`,
-			threat.Name, threat.Description, threat.Source, threat.Object, threat.ConfidentialityViolation, threat.IntegrityViolation, threat.AvailabilityViolation)
-		if err != nil {
-			log.Printf("Ошибка при вставке данных: %v", err)
+	log.Printf("Вставлено угроз: %d", total)
+
+	// Пустой перечень скорее означает ошибку загрузки, чем удаление всех угроз
+	if len(seen) == 0 {
+		log.Println("Перечень угроз пуст, удаленные угрозы не отмечаются")
+		return
+	}
+	// Угрозы, которые не удалось сохранить, отсутствуют в базе не потому, что исключены из перечня
+	if failed > 0 {
+		log.Printf("Не удалось сохранить угроз: %d, удаленные угрозы не отмечаются", failed)
+		return
+	}
+	removed, err := markRemovedThreats(ctx, pool, previous, seen)
+	if err != nil {
+		log.Printf("Ошибка при отметке удаленных угроз: %v", err)
+		return
+	}
+	log.Printf("Отмечено удаленных угроз: %d", removed)
+}
+
+// Функция для вставки пакета угроз и их изменений в одной транзакции. Если в базе есть угрозы без номера
+// (legacy), им присваивается номер угрозы с тем же наименованием, а изменения не записываются:
+// первое сопоставление со старой схемой не является изменением перечня.
+func insertThreatBatch(ctx context.Context, pool *pgxpool.Pool, threats []Threat, previous map[int]threatState, legacy map[string]int) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, threat := range threats {
+		if len(legacy) > 0 {
+			if id, ok := legacy[threat.Name]; ok {
+				if _, known := previous[threat.ThreatID]; !known {
+					batch.Queue(`UPDATE ubi SET threat_id = $1 WHERE id = $2 AND threat_id IS NULL`, threat.ThreatID, id)
+				}
+			}
+			queueThreatUpsert(batch, threat)
+			continue
+		}
+		queueThreatUpsert(batch, threat)
+		if change, ok := detectThreatChange(previous, threat); ok {
+			queueThreatChange(batch, change)
+		}
+	}
+	results := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return err
 		}
 	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Функция для добавления в пакет вставки или обновления угрозы, общая для всех форматов перечня
+func queueThreatUpsert(batch *pgx.Batch, threat Threat) {
+	batch.Queue(`
+        INSERT INTO ubi (threat_id, name, description, source, object, confidentiality_violation, integrity_violation, availability_violation, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+        ON CONFLICT (threat_id) DO UPDATE
+        SET name = EXCLUDED.name,
+            description = EXCLUDED.description,
+            source = EXCLUDED.source,
+            object = EXCLUDED.object,
+            confidentiality_violation = EXCLUDED.confidentiality_violation,
+            integrity_violation = EXCLUDED.integrity_violation,
+            availability_violation = EXCLUDED.availability_violation,
+            removed = FALSE,
+            removed_at = NULL,
+            updated_at = EXCLUDED.updated_at
+        WHERE (ubi.name, ubi.description, ubi.source, ubi.object, ubi.confidentiality_violation, ubi.integrity_violation, ubi.availability_violation, ubi.removed)
+            IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.description, EXCLUDED.source, EXCLUDED.object, EXCLUDED.confidentiality_violation, EXCLUDED.integrity_violation, EXCLUDED.availability_violation, FALSE);`,
+		threat.ThreatID, threat.Name, threat.Description, threat.Source, threat.Object, threat.ConfidentialityViolation, threat.IntegrityViolation, threat.AvailabilityViolation)
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"vkr_common/textnorm"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ThreatSource - источник перечня угроз УБИ в одном из форматов, публикуемых ФСТЭК.
+// Next возвращает очередную угрозу, *skippedRowError для строки, которая не описывает угрозу,
+// или io.EOF, когда угрозы закончились.
+type ThreatSource interface {
+	Next() (Threat, error)
+	Close() error
+}
+
+// skippedRowError - строка перечня пропущена: заголовок, неполная строка или некорректный номер угрозы
+type skippedRowError struct {
+	Row    int
+	Reason string
+}
+
+func (e *skippedRowError) Error() string {
+	return fmt.Sprintf("строка %d пропущена: %s", e.Row, e.Reason)
+}
+
+// Функция для открытия источника угроз по расширению файла
+func openThreatSource(path string) (ThreatSource, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx":
+		return openXLSXThreatSource(path)
+	case ".xml":
+		return openXMLThreatSource(path)
+	case ".csv":
+		return openCSVThreatSource(path)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат перечня угроз: %s", path)
+	}
+}
+
+// Номер угрозы: "УБИ.001", "УБИ 1", "UBI.001" или просто число, как в thrlist.xlsx
+var threatIDPattern = regexp.MustCompile(`(?i)^(?:(?:УБИ|UBI)[\s.:_-]*)?0*([0-9]+)$`)
+
+// Функция для получения номера угрозы из ячейки с идентификатором
+func parseThreatID(s string) (int, bool) {
+	match := threatIDPattern.FindStringSubmatch(textnorm.Normalize(s))
+	if match == nil {
+		return 0, false
+	}
+	threatID, err := strconv.Atoi(match[1])
+	if err != nil || threatID == 0 {
+		return 0, false
+	}
+	return threatID, true
+}
+
+// Функция для преобразования строки таблицы (xlsx, csv) с номером n в угрозу
+func threatFromRow(n int, row []string) (Threat, error) {
+	if len(row) < 8 {
+		return Threat{}, &skippedRowError{Row: n, Reason: fmt.Sprintf("столбцов %d вместо 8", len(row))}
+	}
+	threatID, ok := parseThreatID(row[0])
+	if !ok {
+		return Threat{}, &skippedRowError{Row: n, Reason: fmt.Sprintf("некорректный номер угрозы %q", row[0])}
+	}
+	return Threat{
+		ThreatID:                 threatID,
+		Name:                     row[1],
+		Description:              row[2],
+		Source:                   row[3],
+		Object:                   row[4],
+		ConfidentialityViolation: row[5],
+		IntegrityViolation:       row[6],
+		AvailabilityViolation:    row[7],
+	}, nil
+}
+
+// xlsxThreatSource построчно читает перечень угроз thrlist.xlsx, не загружая лист в память целиком
+type xlsxThreatSource struct {
+	file *excelize.File
+	rows *excelize.Rows
+	row  int
+}
+
+func openXLSXThreatSource(path string) (*xlsxThreatSource, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := f.Rows("Sheet")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxThreatSource{file: f, rows: rows}, nil
+}
+
+func (s *xlsxThreatSource) Next() (Threat, error) {
+	if !s.rows.Next() {
+		if err := s.rows.Error(); err != nil {
+			return Threat{}, err
+		}
+		return Threat{}, io.EOF
+	}
+	s.row++
+	row, err := s.rows.Columns()
+	if err != nil {
+		return Threat{}, err
+	}
+	return threatFromRow(s.row, row)
+}
+
+func (s *xlsxThreatSource) Close() error {
+	rowsErr := s.rows.Close()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return rowsErr
+}
+
+// csvThreatSource читает перечень угроз в CSV с тем же порядком столбцов, что и thrlist.xlsx.
+// Кодировка (UTF-8 или Windows-1251) и разделитель (';' или ',') определяются автоматически.
+type csvThreatSource struct {
+	file   *os.File
+	reader *csv.Reader
+	row    int
+}
+
+func openCSVThreatSource(path string) (*csvThreatSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(f)
+	head, _ := buffered.Peek(4096)
+	head = trimPartialRune(head)
+
+	var r io.Reader = buffered
+	if !utf8.Valid(head) {
+		r = charmap.Windows1251.NewDecoder().Reader(buffered)
+	} else if strings.HasPrefix(string(head), utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = detectDelimiter(head)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return &csvThreatSource{file: f, reader: reader}, nil
+}
+
+func (s *csvThreatSource) Next() (Threat, error) {
+	row, err := s.reader.Read()
+	if err != nil {
+		return Threat{}, err
+	}
+	s.row++
+	return threatFromRow(s.row, row)
+}
+
+func (s *csvThreatSource) Close() error {
+	return s.file.Close()
+}
+
+// Метка порядка байтов, с которой Excel сохраняет CSV в UTF-8
+const utf8BOM = "\ufeff"
+
+// Функция для отбрасывания неполного UTF-8 символа в конце прочитанного фрагмента
+func trimPartialRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return b
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// Функция для определения разделителя CSV по первой строке
+func detectDelimiter(head []byte) rune {
+	line := string(head)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if strings.Count(line, ";") >= strings.Count(line, ",") {
+		return ';'
+	}
+	return ','
+}
+
+// Элементы угрозы в XML-выгрузке перечня угроз. Имена основаны на выгрузке БДУ (identifier, name,
+// description); для остальных полей принимаются также варианты, совпадающие со столбцами ubi.
+var xmlThreatFields = [][]string{
+	{"identifier", "id"},
+	{"name"},
+	{"description"},
+	{"source", "threat_source"},
+	{"object", "impact_object"},
+	{"confidentiality", "confidentiality_violation"},
+	{"integrity", "integrity_violation"},
+	{"availability", "availability_violation"},
+}
+
+// Элементы, содержащие одну угрозу
+var xmlThreatElements = map[string]bool{"threat": true, "ubi": true}
+
+// xmlThreat - угроза в XML-выгрузке перечня угроз: дочерние элементы с текстовыми значениями
+type xmlThreat struct {
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+// Функция для получения значений угрозы в порядке столбцов thrlist.xlsx
+func (t xmlThreat) row() []string {
+	values := make(map[string]string, len(t.Fields))
+	for _, field := range t.Fields {
+		values[strings.ToLower(field.XMLName.Local)] = field.Value
+	}
+	row := make([]string, len(xmlThreatFields))
+	for i, names := range xmlThreatFields {
+		for _, name := range names {
+			if value, ok := values[name]; ok {
+				row[i] = value
+				break
+			}
+		}
+	}
+	return row
+}
+
+// xmlThreatSource потоково читает элементы <threat> (<ubi>) XML-выгрузки перечня угроз
+type xmlThreatSource struct {
+	file    *os.File
+	decoder *xml.Decoder
+	row     int
+}
+
+func openXMLThreatSource(path string) (*xmlThreatSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := xml.NewDecoder(f)
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if strings.EqualFold(label, "windows-1251") {
+			return charmap.Windows1251.NewDecoder().Reader(input), nil
+		}
+		return nil, fmt.Errorf("неподдерживаемая кодировка XML: %s", label)
+	}
+	return &xmlThreatSource{file: f, decoder: decoder}, nil
+}
+
+func (s *xmlThreatSource) Next() (Threat, error) {
+	for {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return Threat{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || !xmlThreatElements[strings.ToLower(start.Name.Local)] {
+			continue
+		}
+
+		var item xmlThreat
+		if err := s.decoder.DecodeElement(&item, &start); err != nil {
+			return Threat{}, err
+		}
+		s.row++
+		return threatFromRow(s.row, item.row())
+	}
+}
+
+func (s *xmlThreatSource) Close() error {
+	return s.file.Close()
+}
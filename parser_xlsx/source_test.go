@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseThreatID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"1", 1, true},
+		{"001", 1, true},
+		{"УБИ.001", 1, true},
+		{"УБИ.222", 222, true},
+		{"уби.15", 15, true},
+		{"УБИ 015", 15, true},
+		{" УБИ.001 ", 1, true},
+		{"UBI.007", 7, true},
+		{"УБИ.000", 0, false},
+		{"УБИ.abc", 0, false},
+		{"Идентификатор УБИ", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseThreatID(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseThreatID(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		head string
+		want rune
+	}{
+		{"a;b;c\n1,2;3", ';'},
+		{"a,b,c\n1;2;3", ','},
+		{"a", ';'},
+	}
+	for _, tt := range tests {
+		if got := detectDelimiter([]byte(tt.head)); got != tt.want {
+			t.Errorf("detectDelimiter(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+// Функция для чтения всех угроз источника с номерами пропущенных строк
+func readAllThreats(t *testing.T, source ThreatSource) ([]Threat, []int) {
+	t.Helper()
+	defer source.Close()
+
+	var threats []Threat
+	var skipped []int
+	for {
+		threat, err := source.Next()
+		if err == io.EOF {
+			return threats, skipped
+		}
+		var skippedErr *skippedRowError
+		if errors.As(err, &skippedErr) {
+			skipped = append(skipped, skippedErr.Row)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next(): %v", err)
+		}
+		threats = append(threats, threat)
+	}
+}
+
+func TestThreatSources(t *testing.T) {
+	tests := []struct {
+		path    string
+		skipped []int
+	}{
+		{"testdata/thrlist_utf8_bom.csv", []int{1, 2, 5}},
+		{"testdata/thrlist_cp1251.csv", []int{1, 2, 5}},
+		{"testdata/thrlist.xml", []int{3}},
+		{"testdata/thrlist_cp1251.xml", []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			source, err := openThreatSource(tt.path)
+			if err != nil {
+				t.Fatalf("openThreatSource: %v", err)
+			}
+			threats, skipped := readAllThreats(t, source)
+
+			if len(threats) != 2 {
+				t.Fatalf("прочитано угроз: %d, want 2", len(threats))
+			}
+			first, second := threats[0].normalized(), threats[1].normalized()
+			if first.ThreatID != 1 || first.Name != "Угроза автоматического распространения вредоносного кода в грид-системе" {
+				t.Errorf("первая угроза = %d %q", first.ThreatID, first.Name)
+			}
+			if first.Description != "Угроза заключается в возможности внедрения; запуска\nвредоносного кода" {
+				t.Errorf("описание первой угрозы = %q", first.Description)
+			}
+			if second.ThreatID != 2 || second.Object != "Сетевой трафик" || second.ConfidentialityViolation != "1" || second.IntegrityViolation != "0" {
+				t.Errorf("вторая угроза = %+v", second)
+			}
+			if len(skipped) != len(tt.skipped) {
+				t.Fatalf("пропущены строки %v, want %v", skipped, tt.skipped)
+			}
+			for i := range skipped {
+				if skipped[i] != tt.skipped[i] {
+					t.Errorf("пропущены строки %v, want %v", skipped, tt.skipped)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestOpenThreatSourceUnsupported(t *testing.T) {
+	if _, err := openThreatSource("testdata/thrlist.pdf"); err == nil {
+		t.Error("openThreatSource(.pdf) без ошибки")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"vkr_common/cveid"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Функция для получения всех идентификаторов CVE уязвимости
+func (v Vulnerability) CVEIDs() []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, identifier := range v.CVEIdentifiers {
+		if !strings.EqualFold(strings.TrimSpace(identifier.Type), "CVE") {
+			continue
+		}
+		for _, id := range cveid.Parse(identifier.Link) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// Функция для приведения ранее загруженных идентификаторов CVE к тому же виду, что и новые:
+// записи со ссылками и несколькими идентификаторами разбиваются, некорректные удаляются
+func normalizeStoredCVEIDs(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	type pendingID struct {
+		link            string
+		vulnerabilityID *int64
+	}
+
+	rows, err := pool.Query(ctx, `SELECT id, link, vulnerability_id FROM cve_identifier`)
+	if err != nil {
+		return 0, err
+	}
+	var stale []int64
+	var pending []pendingID
+	for rows.Next() {
+		var id int64
+		var link *string
+		var vulnerabilityID *int64
+		if err := rows.Scan(&id, &link, &vulnerabilityID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var ids []string
+		if link != nil {
+			ids = cveid.Parse(*link)
+			if len(ids) == 1 && ids[0] == *link {
+				continue
+			}
+		}
+		stale = append(stale, id)
+		for _, cveID := range ids {
+			pending = append(pending, pendingID{link: cveID, vulnerabilityID: vulnerabilityID})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	for _, p := range pending {
+		_, err := tx.Exec(ctx, `INSERT INTO cve_identifier (type, link, vulnerability_id) VALUES ('CVE', $1, $2) ON CONFLICT DO NOTHING`,
+			p.link, p.vulnerabilityID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM cve_identifier WHERE id = ANY($1)`, stale); err != nil {
+		return 0, err
+	}
+	return len(stale), tx.Commit(ctx)
+}
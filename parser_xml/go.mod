@@ -4,5 +4,8 @@ go 1.16
 
 require (
 	github.com/jackc/pgx/v4 v4.18.3
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/joho/godotenv v1.5.1
+	vkr_common v0.0.0
 )
+
+replace vkr_common => ../common
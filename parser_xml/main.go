@@ -12,8 +12,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"vkr_common/cveid"
+	"vkr_common/textnorm"
+
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
@@ -32,6 +36,7 @@ type Vulnerability struct {
 	VulnerableSoftware VulnerableSoftware `xml:"vulnerable_software"`
 	Environment        Environment        `xml:"environment"`
 	CWE                CWE                `xml:"cwe"`
+	CWEs               []CWE              `xml:"cwes>cwe"`
 	IdentifyDate       string             `xml:"identify_date"`
 	CVSS               CVSS               `xml:"cvss"`
 	CVSS3              CVSS3              `xml:"cvss3"`
@@ -78,14 +83,48 @@ type CWE struct {
 	Identifier string `xml:"identifier"`
 }
 
+// Функция для нормализации текстовых полей уязвимости
+func (v Vulnerability) normalized() Vulnerability {
+	v.Name = textnorm.Normalize(v.Name)
+	v.Description = textnorm.Normalize(v.Description)
+	v.Solution = textnorm.Normalize(v.Solution)
+	v.VulStatus = textnorm.Normalize(v.VulStatus)
+	v.ExploitStatus = textnorm.Normalize(v.ExploitStatus)
+	v.FixStatus = textnorm.Normalize(v.FixStatus)
+	v.Sources = textnorm.Normalize(v.Sources)
+	v.Other = textnorm.Normalize(v.Other)
+	v.VulIncident = textnorm.Normalize(v.VulIncident)
+	v.VulClass = textnorm.Normalize(v.VulClass)
+	return v
+}
+
+// Функция для получения всех идентификаторов CWE уязвимости
+func (v Vulnerability) CWEIdentifiers() []string {
+	var identifiers []string
+	seen := make(map[string]bool)
+	for _, cwe := range append([]CWE{v.CWE}, v.CWEs...) {
+		identifier := strings.ToUpper(strings.TrimSpace(cwe.Identifier))
+		if identifier == "" || seen[identifier] {
+			continue
+		}
+		seen[identifier] = true
+		identifiers = append(identifiers, identifier)
+	}
+	return identifiers
+}
+
 type CVSS struct {
-	Vector string `xml:"vector"`
-	Score  string `xml:"score,attr"`
+	Vector CVSSVector `xml:"vector"`
 }
 
 type CVSS3 struct {
-	Vector string `xml:"vector"`
-	Score  string `xml:"score,attr"`
+	Vector CVSSVector `xml:"vector"`
+}
+
+// Вектор CVSS, оценка в выгрузке БДУ указана атрибутом элемента vector
+type CVSSVector struct {
+	Value string `xml:",chardata"`
+	Score string `xml:"score,attr"`
 }
 
 type Identifier struct {
@@ -341,9 +380,21 @@ func createTables(pool *pgxpool.Pool, log *log.Logger) {
 		sources TEXT,
 		other TEXT,
 		vul_incident TEXT,
-		vul_class TEXT
+		vul_class TEXT,
+		cvss_vector TEXT,
+		cvss_score TEXT,
+		cvss3_vector TEXT,
+		cvss3_score TEXT
 	);`
 
+	// Векторы CVSS из выгрузки БДУ, добавленные после первой версии таблицы
+	migrateVulTable := []string{
+		`ALTER TABLE vulnerability ADD COLUMN IF NOT EXISTS cvss_vector TEXT;`,
+		`ALTER TABLE vulnerability ADD COLUMN IF NOT EXISTS cvss_score TEXT;`,
+		`ALTER TABLE vulnerability ADD COLUMN IF NOT EXISTS cvss3_vector TEXT;`,
+		`ALTER TABLE vulnerability ADD COLUMN IF NOT EXISTS cvss3_score TEXT;`,
+	}
+
 	createSoftwareTable := `
 	CREATE TABLE IF NOT EXISTS software (
 		id SERIAL PRIMARY KEY,
@@ -376,11 +427,33 @@ func createTables(pool *pgxpool.Pool, log *log.Logger) {
 		FOREIGN KEY(vulnerability_id) REFERENCES vulnerability(id)
 	);`
 
+	// Удаление повторов после нормализации идентификаторов CVE (normalizeStoredCVEIDs)
+	migrateCveTable := []string{
+		`DELETE FROM cve_identifier a USING cve_identifier b
+		WHERE a.link = b.link AND a.vulnerability_id = b.vulnerability_id AND a.id > b.id;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS cve_identifier_link_vulnerability_id_key ON cve_identifier (link, vulnerability_id);`,
+	}
+
+	createCweTable := `
+	CREATE TABLE IF NOT EXISTS cwe_identifier (
+		id SERIAL PRIMARY KEY,
+		identifier TEXT,
+		vulnerability_id INTEGER,
+		FOREIGN KEY(vulnerability_id) REFERENCES vulnerability(id),
+		UNIQUE(identifier, vulnerability_id)
+	);`
+
 	// Создание таблицы для уязвимостей
 	_, err := pool.Exec(context.Background(), createVulTable)
 	if err != nil {
 		log.Println("Ошибка при создании таблицы уязвимостей:", err)
 	}
+	for _, query := range migrateVulTable {
+		_, err = pool.Exec(context.Background(), query)
+		if err != nil {
+			log.Println("Ошибка при обновлении таблицы уязвимостей:", err)
+		}
+	}
 	// Создание таблицы для программного обеспечения
 	_, err = pool.Exec(context.Background(), createSoftwareTable)
 	if err != nil {
@@ -396,6 +469,22 @@ func createTables(pool *pgxpool.Pool, log *log.Logger) {
 	if err != nil {
 		log.Println("Ошибка при создании таблицы идентификаторов CVE:", err)
 	}
+	if n, err := normalizeStoredCVEIDs(context.Background(), pool); err != nil {
+		log.Println("Ошибка при нормализации идентификаторов CVE:", err)
+	} else if n > 0 {
+		log.Printf("Нормализовано записей идентификаторов CVE: %d\n", n)
+	}
+	for _, query := range migrateCveTable {
+		_, err = pool.Exec(context.Background(), query)
+		if err != nil {
+			log.Println("Ошибка при нормализации идентификаторов CVE:", err)
+		}
+	}
+	// Создание таблицы для идентификаторов CWE
+	_, err = pool.Exec(context.Background(), createCweTable)
+	if err != nil {
+		log.Println("Ошибка при создании таблицы идентификаторов CWE:", err)
+	}
 }
 
 // Функция для вставки данных об уязвимостях в базу данных
@@ -403,14 +492,17 @@ func insertVulnerabilities(pool *pgxpool.Pool, vulnerabilities []Vulnerability,
 	ctx := context.Background()
 
 	for _, vul := range vulnerabilities {
+		vul = vul.normalized()
+
 		var vulnerabilityID int64
 		err := pool.QueryRow(ctx, "SELECT id FROM vulnerability WHERE identifier = $1", vul.Identifier).Scan(&vulnerabilityID)
 		if err == pgx.ErrNoRows {
 			// Вставка новой уязвимости
-			err = pool.QueryRow(ctx, `INSERT INTO vulnerability (identifier, name, description, identify_date, severity, solution, vul_status, exploit_status, fix_status, sources, other, vul_incident, vul_class)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			err = pool.QueryRow(ctx, `INSERT INTO vulnerability (identifier, name, description, identify_date, severity, solution, vul_status, exploit_status, fix_status, sources, other, vul_incident, vul_class, cvss_vector, cvss_score, cvss3_vector, cvss3_score)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 				RETURNING id`,
-				vul.Identifier, vul.Name, vul.Description, vul.IdentifyDate, vul.Severity, vul.Solution, vul.VulStatus, vul.ExploitStatus, vul.FixStatus, vul.Sources, vul.Other, vul.VulIncident, vul.VulClass).Scan(&vulnerabilityID)
+				vul.Identifier, vul.Name, vul.Description, vul.IdentifyDate, vul.Severity, vul.Solution, vul.VulStatus, vul.ExploitStatus, vul.FixStatus, vul.Sources, vul.Other, vul.VulIncident, vul.VulClass,
+				strings.TrimSpace(vul.CVSS.Vector.Value), vul.CVSS.Vector.Score, strings.TrimSpace(vul.CVSS3.Vector.Value), vul.CVSS3.Vector.Score).Scan(&vulnerabilityID)
 			if err != nil {
 				log.Println("Ошибка при вставке уязвимости:", err)
 				continue
@@ -420,6 +512,21 @@ func insertVulnerabilities(pool *pgxpool.Pool, vulnerabilities []Vulnerability,
 			continue
 		} else {
 			log.Println("Уязвимость уже существует:", vul.Identifier)
+			// Текстовые поля и векторы CVSS обновляются и для ранее загруженных уязвимостей,
+			// чтобы записи, сохраненные до нормализации текста, тоже были очищены
+			_, err = pool.Exec(ctx, `UPDATE vulnerability SET name = $2, description = $3, solution = $4, vul_status = $5, exploit_status = $6,
+				fix_status = $7, sources = $8, other = $9, vul_incident = $10, vul_class = $11,
+				cvss_vector = $12, cvss_score = $13, cvss3_vector = $14, cvss3_score = $15
+				WHERE id = $1`,
+				vulnerabilityID, vul.Name, vul.Description, vul.Solution, vul.VulStatus, vul.ExploitStatus,
+				vul.FixStatus, vul.Sources, vul.Other, vul.VulIncident, vul.VulClass,
+				strings.TrimSpace(vul.CVSS.Vector.Value), vul.CVSS.Vector.Score, strings.TrimSpace(vul.CVSS3.Vector.Value), vul.CVSS3.Vector.Score)
+			if err != nil {
+				log.Println("Ошибка при обновлении уязвимости:", err)
+			}
+			// Идентификаторы CVE и CWE дополняются и для ранее загруженных уязвимостей
+			insertCVEIdentifiers(ctx, pool, vul, vulnerabilityID, log)
+			insertCWEIdentifiers(ctx, pool, vul, vulnerabilityID, log)
 			continue
 		}
 
@@ -442,15 +549,40 @@ func insertVulnerabilities(pool *pgxpool.Pool, vulnerabilities []Vulnerability,
 		}
 
 		// Вставка идентификаторов CVE
-		for _, id := range vul.CVEIdentifiers {
-			if id.Type == "CVE" {
-				_, err := pool.Exec(ctx, `INSERT INTO cve_identifier (type, link, vulnerability_id)
-					VALUES ($1, $2, $3)`,
-					id.Type, id.Link, vulnerabilityID)
-				if err != nil {
-					log.Println("Ошибка при вставке идентификатора CVE:", err)
-				}
-			}
+		insertCVEIdentifiers(ctx, pool, vul, vulnerabilityID, log)
+
+		// Вставка идентификаторов CWE
+		insertCWEIdentifiers(ctx, pool, vul, vulnerabilityID, log)
+	}
+}
+
+// Функция для вставки идентификаторов CVE уязвимости в каноническом виде CVE-YYYY-NNNN
+func insertCVEIdentifiers(ctx context.Context, pool *pgxpool.Pool, vul Vulnerability, vulnerabilityID int64, log *log.Logger) {
+	for _, id := range vul.CVEIdentifiers {
+		if strings.EqualFold(strings.TrimSpace(id.Type), "CVE") && len(cveid.Parse(id.Link)) == 0 {
+			log.Printf("Некорректный идентификатор CVE %q в уязвимости %s\n", id.Link, vul.Identifier)
+		}
+	}
+	for _, cveID := range vul.CVEIDs() {
+		_, err := pool.Exec(ctx, `INSERT INTO cve_identifier (type, link, vulnerability_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (link, vulnerability_id) DO NOTHING`,
+			"CVE", cveID, vulnerabilityID)
+		if err != nil {
+			log.Println("Ошибка при вставке идентификатора CVE:", err)
+		}
+	}
+}
+
+// Функция для вставки идентификаторов CWE уязвимости
+func insertCWEIdentifiers(ctx context.Context, pool *pgxpool.Pool, vul Vulnerability, vulnerabilityID int64, log *log.Logger) {
+	for _, identifier := range vul.CWEIdentifiers() {
+		_, err := pool.Exec(ctx, `INSERT INTO cwe_identifier (identifier, vulnerability_id)
+			VALUES ($1, $2)
+			ON CONFLICT (identifier, vulnerability_id) DO NOTHING`,
+			identifier, vulnerabilityID)
+		if err != nil {
+			log.Println("Ошибка при вставке идентификатора CWE:", err)
 		}
 	}
 }